@@ -0,0 +1,161 @@
+// Package admin serves the backend management API of the load balancer.
+// Every request needs "Authorization: Bearer <admin.token>".
+//
+//	GET    /backends                 stats of every backend
+//	POST   /backends                 {"url": "...", "weight": 1} adds a backend
+//	PATCH  /backends?url=...         {"weight": 2, "draining": true} changes one
+//	DELETE /backends?url=...         removes one
+//
+// Changes last until the config file is reloaded.
+package admin
+
+import (
+	"LOAD_BALANCER_SERVICE/balancer"
+	"LOAD_BALANCER_SERVICE/config"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// New returns the admin app, token is read on every request so reloads
+// apply
+func New(pool *balancer.Pool, token func() string) *fiber.App {
+	app := fiber.New(fiber.Config{
+		ErrorHandler:          errorHandler,
+		DisableStartupMessage: true,
+	})
+
+	app.Use(authenticate(token))
+
+	app.Get("/backends", func(c *fiber.Ctx) error {
+		backends := pool.Backends()
+		stats := make([]balancer.Stats, 0, len(backends))
+		for _, backend := range backends {
+			stats = append(stats, backend.Stats())
+		}
+		return c.JSON(fiber.Map{
+			"backends": stats,
+		})
+	})
+
+	app.Post("/backends", func(c *fiber.Ctx) error {
+		var req struct {
+			URL    string `json:"url"`
+			Weight int    `json:"weight"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+		if req.Weight == 0 {
+			req.Weight = 1
+		}
+		if err := validate(req.URL, &req.Weight); err != nil {
+			return err
+		}
+
+		backend, err := pool.Add(req.URL, req.Weight)
+		if err != nil {
+			return poolError(err)
+		}
+		slog.Info("added backend", "backend", req.URL, "weight", req.Weight)
+		return c.Status(fiber.StatusCreated).JSON(backend.Stats())
+	})
+
+	app.Patch("/backends", func(c *fiber.Ctx) error {
+		var req struct {
+			Weight   *int  `json:"weight"`
+			Draining *bool `json:"draining"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+		url := c.Query("url")
+		if err := validate(url, req.Weight); err != nil {
+			return err
+		}
+
+		if req.Weight != nil {
+			if err := pool.SetWeight(url, *req.Weight); err != nil {
+				return poolError(err)
+			}
+			slog.Info("changed backend weight", "backend", url, "weight", *req.Weight)
+		}
+		if req.Draining != nil {
+			if err := pool.SetDraining(url, *req.Draining); err != nil {
+				return poolError(err)
+			}
+			slog.Info("changed backend draining", "backend", url, "draining", *req.Draining)
+		}
+
+		backend, err := pool.Backend(url)
+		if err != nil {
+			return poolError(err)
+		}
+		return c.JSON(backend.Stats())
+	})
+
+	app.Delete("/backends", func(c *fiber.Ctx) error {
+		url := c.Query("url")
+		if err := pool.Remove(url); err != nil {
+			return poolError(err)
+		}
+		slog.Info("removed backend", "backend", url)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	return app
+}
+
+// authenticate compares bearer tokens in constant time, nothing gets in
+// while the token is unset
+func authenticate(token func() string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		expected := token()
+		presented, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if expected == "" || !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(expected)) != 1 {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid admin token")
+		}
+		return c.Next()
+	}
+}
+
+// validate checks a backend URL and, when set, a weight
+func validate(url string, weight *int) error {
+	if err := config.ValidateBackend(url); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if weight != nil {
+		if err := config.ValidateWeight(*weight); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+	return nil
+}
+
+// poolError maps pool errors to HTTP errors
+func poolError(err error) error {
+	switch {
+	case errors.Is(err, balancer.ErrUnknownBackend):
+		return fiber.NewError(fiber.StatusNotFound, "Unknown backend")
+	case errors.Is(err, balancer.ErrBackendExists):
+		return fiber.NewError(fiber.StatusConflict, "Backend already exists")
+	case errors.Is(err, balancer.ErrLastBackend):
+		return fiber.NewError(fiber.StatusConflict, "The last backend cannot be removed")
+	}
+	return err
+}
+
+// errorHandler answers errors as {"error": "..."}
+func errorHandler(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		status = fiberErr.Code
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
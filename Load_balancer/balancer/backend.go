@@ -0,0 +1,128 @@
+package balancer
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Backend is one upstream server and its health state
+type Backend struct {
+	URL string
+
+	// ejected is read on every request, the rest only on state changes
+	ejected  atomic.Bool
+	weight   atomic.Int32
+	inFlight atomic.Int64
+	draining atomic.Bool
+	breaker  breaker
+	stats    stats
+
+	lock         sync.Mutex
+	failures     int       // Consecutive failed checks or proxy errors
+	successes    int       // Consecutive passed checks
+	ejections    int       // Ejections in a row, each one lasts twice as long
+	ejectedUntil time.Time // Earliest readmission
+	admittedAt   time.Time
+}
+
+func newBackend(url string) *Backend {
+	backend := &Backend{URL: url, admittedAt: time.Now()}
+	backend.weight.Store(1)
+	return backend
+}
+
+// Weight is the backend's share of the traffic relative to the others
+func (b *Backend) Weight() int {
+	return int(b.weight.Load())
+}
+
+// InFlight is the number of requests being proxied to the backend
+func (b *Backend) InFlight() int64 {
+	return b.inFlight.Load()
+}
+
+// BreakerState returns the state of the backend's circuit breaker
+func (b *Backend) BreakerState() string {
+	return b.breaker.State()
+}
+
+// Done ends a request handed out by Pool.Next
+func (b *Backend) Done() {
+	b.inFlight.Add(-1)
+}
+
+// lessLoaded reports whether b has fewer requests in flight than other
+// relative to their weights
+func (b *Backend) lessLoaded(other *Backend) bool {
+	return b.InFlight()*int64(other.Weight()) < other.InFlight()*int64(b.Weight())
+}
+
+// Available reports whether the backend may receive new requests
+func (b *Backend) Available() bool {
+	return !b.ejected.Load() && !b.draining.Load()
+}
+
+// Draining reports whether the backend was drained, it keeps serving the
+// requests it has but gets no new ones
+func (b *Backend) Draining() bool {
+	return b.draining.Load()
+}
+
+// fail records a failed check or proxy error and reports whether it ejected
+// the backend, and for how long
+func (b *Backend) fail(settings config.HealthCheck, now time.Time) (bool, time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.successes = 0
+	b.failures++
+	if b.ejected.Load() || b.failures < settings.UnhealthyThreshold {
+		return false, 0
+	}
+
+	// A backend that fails again soon after coming back stays out longer
+	if now.Sub(b.admittedAt) < settings.MaxEjection.Std() {
+		b.ejections++
+	} else {
+		b.ejections = 1
+	}
+
+	duration := settings.BaseEjection.Std()
+	for i := 1; i < b.ejections && duration < settings.MaxEjection.Std(); i++ {
+		duration *= 2
+	}
+	duration = min(duration, settings.MaxEjection.Std())
+
+	b.ejectedUntil = now.Add(duration)
+	b.ejected.Store(true)
+	return true, duration
+}
+
+// pass records a passed check and reports whether it readmitted the backend
+func (b *Backend) pass(settings config.HealthCheck, now time.Time) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures = 0
+	b.successes++
+	if !b.ejected.Load() || now.Before(b.ejectedUntil) || b.successes < settings.HealthyThreshold {
+		return false
+	}
+
+	b.admittedAt = now
+	b.ejected.Store(false)
+	return true
+}
+
+// served records a proxied request, which only clears earlier errors so
+// occasional ones never add up to an ejection
+func (b *Backend) served() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.ejected.Load() {
+		b.failures = 0
+	}
+}
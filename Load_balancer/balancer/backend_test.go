@@ -0,0 +1,78 @@
+package balancer
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"testing"
+	"time"
+
+	shared "SHARED_CONFIG"
+)
+
+var testHealthCheck = config.HealthCheck{
+	UnhealthyThreshold: 2,
+	HealthyThreshold:   1,
+	BaseEjection:       shared.Duration(10 * time.Second),
+	MaxEjection:        shared.Duration(30 * time.Second),
+}
+
+// eject fails backend until it is ejected and returns for how long
+func eject(t *testing.T, backend *Backend, now time.Time) time.Duration {
+	t.Helper()
+
+	if ejected, _ := backend.fail(testHealthCheck, now); ejected {
+		t.Fatal("ejected below the unhealthy threshold")
+	}
+	ejected, duration := backend.fail(testHealthCheck, now)
+	if !ejected {
+		t.Fatal("not ejected at the unhealthy threshold")
+	}
+	if backend.Available() {
+		t.Fatal("ejected backend is available")
+	}
+	return duration
+}
+
+func TestBackendEjectionBacksOff(t *testing.T) {
+	now := time.Now()
+	backend := newBackend("http://backend")
+
+	// Each ejection that follows a quick readmission lasts twice as long, up
+	// to the maximum
+	for _, want := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second} {
+		duration := eject(t, backend, now)
+		if duration != want {
+			t.Fatalf("ejected for %v, want %v", duration, want)
+		}
+
+		if backend.pass(testHealthCheck, now.Add(duration-time.Second)) {
+			t.Fatal("readmitted before the ejection ended")
+		}
+		now = now.Add(duration)
+		if !backend.pass(testHealthCheck, now) || !backend.Available() {
+			t.Fatal("not readmitted after the ejection ended")
+		}
+	}
+}
+
+func TestBackendEjectionResetsAfterStablePeriod(t *testing.T) {
+	now := time.Now()
+	backend := newBackend("http://backend")
+
+	duration := eject(t, backend, now)
+	now = now.Add(duration)
+	backend.pass(testHealthCheck, now)
+
+	if duration := eject(t, backend, now.Add(testHealthCheck.MaxEjection.Std())); duration != 10*time.Second {
+		t.Errorf("ejected for %v after a stable period, want the base ejection", duration)
+	}
+}
+
+func TestServedClearsFailures(t *testing.T) {
+	backend := newBackend("http://backend")
+
+	backend.fail(testHealthCheck, time.Now())
+	backend.served()
+	if ejected, _ := backend.fail(testHealthCheck, time.Now()); ejected {
+		t.Error("a failure before a served request counted towards ejection")
+	}
+}
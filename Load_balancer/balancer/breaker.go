@@ -0,0 +1,96 @@
+package balancer
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// breaker keeps requests away from a backend whose requests keep failing,
+// reacting faster than the health checks
+type breaker struct {
+	lock      sync.Mutex
+	state     string
+	failures  int // Consecutive failed requests while closed
+	openUntil time.Time
+	trials    int // Requests let through while half open
+}
+
+// allows reports whether a request may be sent, without reserving anything
+func (b *breaker) allows(settings config.CircuitBreaker, now time.Time) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		return !now.Before(b.openUntil)
+	case BreakerHalfOpen:
+		return b.trials < settings.HalfOpenRequests
+	}
+	return true
+}
+
+// acquire reserves a request, an open breaker whose timeout passed turns
+// half open and lets a few trial requests through
+func (b *breaker) acquire(settings config.CircuitBreaker, now time.Time) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Before(b.openUntil) {
+			return false
+		}
+		b.state, b.trials = BreakerHalfOpen, 0
+		fallthrough
+	case BreakerHalfOpen:
+		if b.trials >= settings.HalfOpenRequests {
+			return false
+		}
+		b.trials++
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of a request and returns the
+// state it changed to, or an empty string
+func (b *breaker) record(settings config.CircuitBreaker, failed bool, now time.Time) string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch {
+	case !failed && b.state == BreakerHalfOpen:
+		b.state, b.failures = BreakerClosed, 0
+		return BreakerClosed
+	case !failed:
+		b.failures = 0
+	case b.state == BreakerHalfOpen:
+		b.state, b.openUntil = BreakerOpen, now.Add(settings.OpenTimeout.Std())
+		return BreakerOpen
+	case b.state != BreakerOpen:
+		b.failures++
+		if b.failures >= settings.FailureThreshold {
+			b.state, b.openUntil = BreakerOpen, now.Add(settings.OpenTimeout.Std())
+			return BreakerOpen
+		}
+	}
+	return ""
+}
+
+// State returns the current state
+func (b *breaker) State() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == "" {
+		return BreakerClosed
+	}
+	return b.state
+}
@@ -0,0 +1,91 @@
+package balancer
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"testing"
+	"time"
+
+	shared "SHARED_CONFIG"
+)
+
+var testBreaker = config.CircuitBreaker{
+	FailureThreshold: 3,
+	OpenTimeout:      shared.Duration(10 * time.Second),
+	HalfOpenRequests: 2,
+}
+
+// call sends one request through b at now and records its outcome
+func call(t *testing.T, b *breaker, now time.Time, failed bool) string {
+	t.Helper()
+
+	if !b.acquire(testBreaker, now) {
+		t.Fatalf("request at %s refused in state %s", now.Format(time.TimeOnly), b.State())
+	}
+	return b.record(testBreaker, failed, now)
+}
+
+func TestBreakerOpensAtThreshold(t *testing.T) {
+	now := time.Now()
+	var b breaker
+
+	call(t, &b, now, true)
+	call(t, &b, now, true)
+	call(t, &b, now, false) // A success forgets earlier failures
+	call(t, &b, now, true)
+	if call(t, &b, now, true) != "" || b.State() != BreakerClosed {
+		t.Fatalf("State() = %s after two failures, want closed", b.State())
+	}
+
+	if changed := call(t, &b, now, true); changed != BreakerOpen {
+		t.Fatalf("record() = %q at the threshold, want %q", changed, BreakerOpen)
+	}
+	if b.allows(testBreaker, now.Add(9*time.Second)) {
+		t.Error("open breaker allows requests before the timeout")
+	}
+	if !b.allows(testBreaker, now.Add(10*time.Second)) {
+		t.Error("open breaker refuses trials after the timeout")
+	}
+}
+
+func TestBreakerTrials(t *testing.T) {
+	open := func(t *testing.T) (*breaker, time.Time) {
+		b := &breaker{}
+		now := time.Now()
+		for range testBreaker.FailureThreshold {
+			call(t, b, now, true)
+		}
+		return b, now.Add(testBreaker.OpenTimeout.Std())
+	}
+
+	t.Run("good trial closes", func(t *testing.T) {
+		b, later := open(t)
+		if changed := call(t, b, later, false); changed != BreakerClosed {
+			t.Errorf("record() = %q, want %q", changed, BreakerClosed)
+		}
+	})
+
+	t.Run("bad trial reopens", func(t *testing.T) {
+		b, later := open(t)
+		if changed := call(t, b, later, true); changed != BreakerOpen {
+			t.Errorf("record() = %q, want %q", changed, BreakerOpen)
+		}
+		if b.allows(testBreaker, later.Add(9*time.Second)) {
+			t.Error("reopened breaker allows requests before a new timeout")
+		}
+	})
+
+	t.Run("trials are limited", func(t *testing.T) {
+		b, later := open(t)
+		for i := range testBreaker.HalfOpenRequests {
+			if !b.acquire(testBreaker, later) {
+				t.Fatalf("trial %d refused", i+1)
+			}
+		}
+		if b.State() != BreakerHalfOpen {
+			t.Errorf("State() = %q, want %q", b.State(), BreakerHalfOpen)
+		}
+		if b.acquire(testBreaker, later) || b.allows(testBreaker, later) {
+			t.Error("a trial over half_open_requests was let through")
+		}
+	})
+}
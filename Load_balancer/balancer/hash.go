@@ -0,0 +1,123 @@
+package balancer
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"hash/crc32"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// pointsPerWeight is how many points each unit of weight puts on the ring,
+// enough for an even spread over a handful of backends
+const pointsPerWeight = 100
+
+type ringPoint struct {
+	hash    uint32
+	backend *Backend
+}
+
+// consistentHash sends requests with the same key to the same backend, an
+// ejected backend only moves its own keys elsewhere
+type consistentHash struct {
+	hashKey string
+
+	lock    sync.Mutex
+	ring    []ringPoint
+	members []*Backend // Backends the ring was built for
+	weights []int
+}
+
+func newConsistentHash(hashKey string) *consistentHash {
+	return &consistentHash{hashKey: hashKey}
+}
+
+func (s *consistentHash) Pick(backends []*Backend, c *fiber.Ctx) *Backend {
+	ring := s.ringFor(backends)
+
+	hash := crc32.ChecksumIEEE([]byte(s.key(c)))
+	i := sort.Search(len(ring), func(i int) bool {
+		return ring[i].hash >= hash
+	})
+	if i == len(ring) {
+		i = 0
+	}
+	return ring[i].backend
+}
+
+// key returns the request's hash key, requests without one are keyed by
+// client IP
+func (s *consistentHash) key(c *fiber.Ctx) string {
+	switch s.hashKey {
+	case config.HashKeyUser:
+		if user := c.Get("X-Username"); user != "" {
+			return "user:" + user
+		}
+		if apiKey := c.Get(fiber.HeaderAuthorization); apiKey != "" {
+			return "key:" + apiKey
+		}
+	case config.HashKeyImageID:
+		if imageID := imageID(c.Path()); imageID != "" {
+			return "image:" + imageID
+		}
+	}
+	return "ip:" + ClientIP(c)
+}
+
+const clientIPKey = "client_ip"
+
+// SetClientIP records the client address resolved from trusted forwarding
+// headers
+func SetClientIP(c *fiber.Ctx, ip string) {
+	c.Locals(clientIPKey, ip)
+}
+
+// ClientIP returns the address set by SetClientIP, or the remote address
+func ClientIP(c *fiber.Ctx) string {
+	if ip, ok := c.Locals(clientIPKey).(string); ok {
+		return ip
+	}
+	return c.IP()
+}
+
+// imageID returns the ID in /images/:id paths and their subpaths
+func imageID(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < 2 || segments[0] != "images" || segments[1] == "search" {
+		return ""
+	}
+	return segments[1]
+}
+
+// ringFor returns the ring of the backends, rebuilding it when they or
+// their weights changed
+func (s *consistentHash) ringFor(backends []*Backend) []ringPoint {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	weights := make([]int, len(backends))
+	for i, backend := range backends {
+		weights[i] = backend.Weight()
+	}
+	if slices.Equal(s.members, backends) && slices.Equal(s.weights, weights) {
+		return s.ring
+	}
+
+	ring := make([]ringPoint, 0, len(backends)*pointsPerWeight)
+	for i, backend := range backends {
+		for point := 0; point < weights[i]*pointsPerWeight; point++ {
+			hash := crc32.ChecksumIEEE([]byte(backend.URL + "#" + strconv.Itoa(point)))
+			ring = append(ring, ringPoint{hash: hash, backend: backend})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+
+	s.ring, s.members, s.weights = ring, slices.Clone(backends), weights
+	return ring
+}
@@ -0,0 +1,50 @@
+package balancer
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// checkAll checks the backends concurrently so a hanging one does not delay
+// the others
+func (p *Pool) checkAll() {
+	var wg sync.WaitGroup
+	for _, backend := range p.Backends() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.check(backend)
+		}()
+	}
+	wg.Wait()
+}
+
+// check probes the backend's readiness endpoint, any 2xx answer passes
+func (p *Pool) check(backend *Backend) {
+	settings := *p.healthCheck.Load()
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(backend.URL + settings.Path)
+	req.Header.SetMethod(fasthttp.MethodGet)
+
+	err := p.client.DoTimeout(req, resp, settings.Timeout.Std())
+	if err == nil && (resp.StatusCode() < 200 || resp.StatusCode() > 299) {
+		err = fmt.Errorf("%s answered %d", settings.Path, resp.StatusCode())
+	}
+	if err != nil {
+		p.fail(backend, "health check", err)
+		return
+	}
+
+	if backend.pass(settings, time.Now()) {
+		slog.Info("readmitted backend", "backend", backend.URL)
+	}
+}
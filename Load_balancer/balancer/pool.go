@@ -0,0 +1,240 @@
+// Package balancer picks the backend of each proxied request, keeping
+// failing backends out of rotation
+package balancer
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	// ErrNoBackend is returned when every backend is ejected, drained or has
+	// an open circuit breaker
+	ErrNoBackend = errors.New("no healthy backend")
+
+	ErrBackendExists  = errors.New("backend already exists")
+	ErrUnknownBackend = errors.New("unknown backend")
+	ErrLastBackend    = errors.New("the last backend cannot be removed")
+)
+
+// Pool is the set of backends, health checked in the background
+type Pool struct {
+	// Readers load backends without locking, writers replace the list
+	// under lock
+	lock        sync.Mutex
+	backends    atomic.Pointer[[]*Backend]
+	healthCheck atomic.Pointer[config.HealthCheck]
+	breaker     atomic.Pointer[config.CircuitBreaker]
+	strategy    atomic.Pointer[strategyState]
+	client      *fasthttp.Client
+	done        chan bool
+}
+
+// strategyState is a strategy and the settings it was built from
+type strategyState struct {
+	settings config.Balancing
+	Strategy
+}
+
+// NewPool returns a pool of the configured backends, all of them admitted
+// until proven unhealthy. Health checks of https backends use tlsConfig.
+func NewPool(cfg *config.Config, tlsConfig *tls.Config) *Pool {
+	pool := &Pool{
+		client: &fasthttp.Client{Name: "load_balancer health check", TLSConfig: tlsConfig},
+		done:   make(chan bool),
+	}
+	pool.Configure(cfg)
+	return pool
+}
+
+// Configure applies the backend list and the balancing, health check and
+// circuit breaker settings, replacing backends and weights changed through
+// the admin API. Backends kept in the list keep their state, requests in
+// flight keep the backend they were given.
+func (p *Pool) Configure(cfg *config.Config) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.healthCheck.Store(&cfg.HealthCheck)
+	p.breaker.Store(&cfg.CircuitBreaker)
+
+	existing := map[string]*Backend{}
+	if current := p.backends.Load(); current != nil {
+		for _, backend := range *current {
+			existing[backend.URL] = backend
+		}
+	}
+
+	backends := make([]*Backend, 0, len(cfg.Backends))
+	for _, url := range cfg.Backends {
+		backend, ok := existing[url]
+		if !ok {
+			backend = newBackend(url)
+		}
+		backend.weight.Store(int32(cfg.Balancing.Weight(url)))
+		backends = append(backends, backend)
+	}
+	p.backends.Store(&backends)
+
+	// Strategies keep state, only replace them when their settings change
+	balancing := cfg.Balancing
+	if current := p.strategy.Load(); current == nil || current.settings.Strategy != balancing.Strategy || current.settings.HashKey != balancing.HashKey {
+		p.strategy.Store(&strategyState{settings: balancing, Strategy: NewStrategy(balancing)})
+	}
+}
+
+// Backends returns the current backends
+func (p *Pool) Backends() []*Backend {
+	return *p.backends.Load()
+}
+
+// Backend returns the backend with the URL
+func (p *Pool) Backend(url string) (*Backend, error) {
+	for _, backend := range p.Backends() {
+		if backend.URL == url {
+			return backend, nil
+		}
+	}
+	return nil, ErrUnknownBackend
+}
+
+// Add adds a backend until the next config reload
+func (p *Pool) Add(url string, weight int) (*Backend, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, err := p.Backend(url); err == nil {
+		return nil, ErrBackendExists
+	}
+
+	backend := newBackend(url)
+	backend.weight.Store(int32(weight))
+	backends := append(slices.Clone(p.Backends()), backend)
+	p.backends.Store(&backends)
+	return backend, nil
+}
+
+// Remove removes a backend until the next config reload, its requests in
+// flight complete
+func (p *Pool) Remove(url string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	backend, err := p.Backend(url)
+	if err != nil {
+		return err
+	}
+	if len(p.Backends()) == 1 {
+		return ErrLastBackend
+	}
+
+	backends := slices.DeleteFunc(slices.Clone(p.Backends()), func(b *Backend) bool {
+		return b == backend
+	})
+	p.backends.Store(&backends)
+	return nil
+}
+
+// SetWeight changes the weight of a backend until the next config reload
+func (p *Pool) SetWeight(url string, weight int) error {
+	backend, err := p.Backend(url)
+	if err != nil {
+		return err
+	}
+	backend.weight.Store(int32(weight))
+	return nil
+}
+
+// SetDraining stops or resumes sending new requests to a backend
+func (p *Pool) SetDraining(url string, draining bool) error {
+	backend, err := p.Backend(url)
+	if err != nil {
+		return err
+	}
+	backend.draining.Store(draining)
+	return nil
+}
+
+// Next selects the backend of a request with the configured strategy among
+// the available backends, leaving out the excluded ones. The caller must
+// Report the outcome and call Done once the request is over.
+func (p *Pool) Next(c *fiber.Ctx, exclude ...*Backend) (*Backend, error) {
+	now := time.Now()
+	settings := *p.breaker.Load()
+
+	var available []*Backend
+	for _, backend := range p.Backends() {
+		if backend.Available() && backend.breaker.allows(settings, now) && !slices.Contains(exclude, backend) {
+			available = append(available, backend)
+		}
+	}
+
+	// Another request may take the last trial of a half open breaker first
+	for len(available) > 0 {
+		backend := p.strategy.Load().Pick(available, c)
+		if backend.breaker.acquire(settings, now) {
+			backend.inFlight.Add(1)
+			return backend, nil
+		}
+		available = slices.DeleteFunc(available, func(b *Backend) bool {
+			return b == backend
+		})
+	}
+	return nil, ErrNoBackend
+}
+
+// Report records the outcome of a proxied request. Proxy errors and gateway
+// errors from the backend count against its circuit breaker, enough proxy
+// errors in a row eject it without waiting for the next check.
+func (p *Pool) Report(backend *Backend, err error, status int, latency time.Duration) {
+	failed := err != nil || status == fiber.StatusBadGateway || status == fiber.StatusServiceUnavailable || status == fiber.StatusGatewayTimeout
+	backend.stats.record(latency, failed)
+	switch backend.breaker.record(*p.breaker.Load(), failed, time.Now()) {
+	case BreakerOpen:
+		slog.Warn("opened circuit breaker", "backend", backend.URL)
+	case BreakerClosed:
+		slog.Info("closed circuit breaker", "backend", backend.URL)
+	}
+
+	if err != nil {
+		p.fail(backend, "proxy error", err)
+	} else {
+		backend.served()
+	}
+}
+
+func (p *Pool) fail(backend *Backend, reason string, err error) {
+	if ejected, duration := backend.fail(*p.healthCheck.Load(), time.Now()); ejected {
+		slog.Warn("ejected backend", "backend", backend.URL, "reason", reason, "err", err, "for", duration.String())
+	}
+}
+
+// StartHealthChecks checks every backend now and then every interval until
+// Stop
+func (p *Pool) StartHealthChecks() {
+	go func() {
+		for {
+			p.checkAll()
+
+			select {
+			case <-time.After(p.healthCheck.Load().Interval.Std()):
+			case <-p.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the health checks
+func (p *Pool) Stop() {
+	close(p.done)
+}
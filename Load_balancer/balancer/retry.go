@@ -0,0 +1,57 @@
+package balancer
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"sync"
+)
+
+// RetryBudget caps retries to a share of the traffic so retries cannot
+// multiply the load on backends that are already struggling
+type RetryBudget struct {
+	lock     sync.Mutex
+	tokens   float64
+	settings config.Retry
+}
+
+// NewRetryBudget returns a full budget
+func NewRetryBudget(settings config.Retry) *RetryBudget {
+	return &RetryBudget{tokens: float64(settings.BudgetBurst), settings: settings}
+}
+
+// SetSettings applies new retry settings, keeping the tokens saved so far
+func (r *RetryBudget) SetSettings(settings config.Retry) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.settings = settings
+	r.tokens = min(r.tokens, float64(settings.BudgetBurst))
+}
+
+// Settings returns the current retry settings
+func (r *RetryBudget) Settings() config.Retry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.settings
+}
+
+// Deposit is called once for every request
+func (r *RetryBudget) Deposit() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.tokens = min(r.tokens+r.settings.BudgetRatio, float64(r.settings.BudgetBurst))
+}
+
+// Withdraw reports whether a retry is within budget, taking it from the
+// budget when it is
+func (r *RetryBudget) Withdraw() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
@@ -0,0 +1,105 @@
+package balancer
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// statsWindow is how many recent requests the error rate and latency
+// percentiles are computed over
+const statsWindow = 1024
+
+type outcome struct {
+	latency time.Duration
+	failed  bool
+}
+
+// stats keeps the totals and the recent requests of a backend
+type stats struct {
+	lock     sync.Mutex
+	requests int64
+	failures int64
+	recent   [statsWindow]outcome
+	next     int // Slot of the next outcome in recent
+	filled   int
+}
+
+func (s *stats) record(latency time.Duration, failed bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requests++
+	if failed {
+		s.failures++
+	}
+	s.recent[s.next] = outcome{latency: latency, failed: failed}
+	s.next = (s.next + 1) % statsWindow
+	s.filled = min(s.filled+1, statsWindow)
+}
+
+// Stats is what the admin API reports for a backend
+type Stats struct {
+	URL          string     `json:"url"`
+	Weight       int        `json:"weight"`
+	Draining     bool       `json:"draining"`
+	Health       string     `json:"health"` // "healthy" or "ejected"
+	EjectedUntil *time.Time `json:"ejected_until,omitempty"`
+	Breaker      string     `json:"breaker"`
+	InFlight     int64      `json:"in_flight"`
+	Requests     int64      `json:"requests"`
+	Failures     int64      `json:"failures"`
+	// Over the last requests, at most statsWindow of them
+	ErrorRate float64 `json:"error_rate"`
+	LatencyMs struct {
+		P50 float64 `json:"p50"`
+		P90 float64 `json:"p90"`
+		P99 float64 `json:"p99"`
+	} `json:"latency_ms"`
+}
+
+// Stats returns the backend's state and request statistics
+func (b *Backend) Stats() Stats {
+	stats := Stats{
+		URL:      b.URL,
+		Weight:   b.Weight(),
+		Draining: b.Draining(),
+		Health:   "healthy",
+		Breaker:  b.BreakerState(),
+		InFlight: b.InFlight(),
+	}
+
+	b.lock.Lock()
+	if b.ejected.Load() {
+		ejectedUntil := b.ejectedUntil
+		stats.Health, stats.EjectedUntil = "ejected", &ejectedUntil
+	}
+	b.lock.Unlock()
+
+	b.stats.lock.Lock()
+	stats.Requests, stats.Failures = b.stats.requests, b.stats.failures
+	latencies := make([]time.Duration, 0, b.stats.filled)
+	failures := 0
+	for _, outcome := range b.stats.recent[:b.stats.filled] {
+		latencies = append(latencies, outcome.latency)
+		if outcome.failed {
+			failures++
+		}
+	}
+	b.stats.lock.Unlock()
+
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		stats.ErrorRate = float64(failures) / float64(len(latencies))
+		stats.LatencyMs.P50 = percentile(latencies, 0.50)
+		stats.LatencyMs.P90 = percentile(latencies, 0.90)
+		stats.LatencyMs.P99 = percentile(latencies, 0.99)
+	}
+	return stats
+}
+
+// percentile returns the q-th percentile of sorted latencies in milliseconds
+func percentile(sorted []time.Duration, q float64) float64 {
+	index := int(q * float64(len(sorted)-1))
+	return float64(sorted[index].Microseconds()) / 1000
+}
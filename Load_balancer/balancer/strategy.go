@@ -0,0 +1,111 @@
+package balancer
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Strategy picks the backend of a request among the available ones, which
+// are never empty
+type Strategy interface {
+	Pick(backends []*Backend, c *fiber.Ctx) *Backend
+}
+
+// NewStrategy returns the strategy named in the balancing config
+func NewStrategy(settings config.Balancing) Strategy {
+	switch settings.Strategy {
+	case config.StrategyWeightedRoundRobin:
+		return &weightedRoundRobin{current: map[*Backend]int{}}
+	case config.StrategyLeastConnections:
+		return &leastConnections{}
+	case config.StrategyPowerOfTwo:
+		return powerOfTwo{}
+	case config.StrategyConsistentHash:
+		return newConsistentHash(settings.HashKey)
+	}
+	return &roundRobin{}
+}
+
+// roundRobin takes turns
+type roundRobin struct {
+	counter atomic.Uint32
+}
+
+func (s *roundRobin) Pick(backends []*Backend, _ *fiber.Ctx) *Backend {
+	return backends[s.counter.Add(1)%uint32(len(backends))]
+}
+
+// weightedRoundRobin takes turns in proportion to the weights, spreading the
+// turns of heavy backends out rather than giving them in a row
+type weightedRoundRobin struct {
+	lock    sync.Mutex
+	current map[*Backend]int
+}
+
+func (s *weightedRoundRobin) Pick(backends []*Backend, _ *fiber.Ctx) *Backend {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var best *Backend
+	total := 0
+	for _, backend := range backends {
+		s.current[backend] += backend.Weight()
+		total += backend.Weight()
+		if best == nil || s.current[backend] > s.current[best] {
+			best = backend
+		}
+	}
+	s.current[best] -= total
+
+	// Forget backends that were removed or ejected
+	if len(s.current) > len(backends) {
+		for backend := range s.current {
+			if !slices.Contains(backends, backend) {
+				delete(s.current, backend)
+			}
+		}
+	}
+	return best
+}
+
+// leastConnections picks the backend with the fewest requests in flight per
+// weight, ties go round-robin so idle backends share the load
+type leastConnections struct {
+	counter atomic.Uint32
+}
+
+func (s *leastConnections) Pick(backends []*Backend, _ *fiber.Ctx) *Backend {
+	start := s.counter.Add(1)
+	best := backends[start%uint32(len(backends))]
+	for i := 1; i < len(backends); i++ {
+		backend := backends[(start+uint32(i))%uint32(len(backends))]
+		if backend.lessLoaded(best) {
+			best = backend
+		}
+	}
+	return best
+}
+
+// powerOfTwo compares two random backends and takes the less loaded one,
+// nearly as even as least connections without scanning every backend
+type powerOfTwo struct{}
+
+func (powerOfTwo) Pick(backends []*Backend, _ *fiber.Ctx) *Backend {
+	if len(backends) == 1 {
+		return backends[0]
+	}
+	first := rand.IntN(len(backends))
+	second := rand.IntN(len(backends) - 1)
+	if second >= first {
+		second++
+	}
+	if backends[second].lessLoaded(backends[first]) {
+		return backends[second]
+	}
+	return backends[first]
+}
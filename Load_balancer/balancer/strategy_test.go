@@ -0,0 +1,169 @@
+package balancer
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// testBackends returns backends with the given weights and requests in flight
+func testBackends(weights []int, inFlight []int64) []*Backend {
+	backends := make([]*Backend, len(weights))
+	for i, weight := range weights {
+		backends[i] = newBackend(fmt.Sprintf("http://backend-%d", i))
+		backends[i].weight.Store(int32(weight))
+		if inFlight != nil {
+			backends[i].inFlight.Store(inFlight[i])
+		}
+	}
+	return backends
+}
+
+// pickCounts picks n times and counts the picks of each backend by index
+func pickCounts(strategy Strategy, backends []*Backend, n int) []int {
+	counts := make([]int, len(backends))
+	for range n {
+		picked := strategy.Pick(backends, nil)
+		for i, backend := range backends {
+			if backend == picked {
+				counts[i]++
+			}
+		}
+	}
+	return counts
+}
+
+func TestStrategyDistribution(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		weights  []int
+		inFlight []int64
+		picks    int
+		want     []int
+	}{
+		{name: "round robin", strategy: config.StrategyRoundRobin, weights: []int{1, 1, 1}, picks: 300, want: []int{100, 100, 100}},
+		{name: "round robin ignores weights", strategy: config.StrategyRoundRobin, weights: []int{5, 1}, picks: 10, want: []int{5, 5}},
+		{name: "weighted round robin", strategy: config.StrategyWeightedRoundRobin, weights: []int{5, 1, 1}, picks: 700, want: []int{500, 100, 100}},
+		{name: "least connections", strategy: config.StrategyLeastConnections, weights: []int{1, 1, 1}, inFlight: []int64{4, 1, 2}, picks: 10, want: []int{0, 10, 0}},
+		{name: "least connections per weight", strategy: config.StrategyLeastConnections, weights: []int{4, 1}, inFlight: []int64{4, 2}, picks: 10, want: []int{10, 0}},
+		{name: "least connections ties", strategy: config.StrategyLeastConnections, weights: []int{1, 1}, inFlight: []int64{0, 0}, picks: 10, want: []int{5, 5}},
+		{name: "p2c with two backends", strategy: config.StrategyPowerOfTwo, weights: []int{1, 1}, inFlight: []int64{3, 0}, picks: 10, want: []int{0, 10}},
+		{name: "p2c with one backend", strategy: config.StrategyPowerOfTwo, weights: []int{1}, picks: 10, want: []int{10}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			strategy := NewStrategy(config.Balancing{Strategy: test.strategy})
+			got := pickCounts(strategy, testBackends(test.weights, test.inFlight), test.picks)
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("picks = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWeightedRoundRobinSpreadsTurns(t *testing.T) {
+	backends := testBackends([]int{2, 1}, nil)
+	strategy := NewStrategy(config.Balancing{Strategy: config.StrategyWeightedRoundRobin})
+
+	// The heavy backend never gets three turns in a row
+	run := 0
+	for range 30 {
+		if strategy.Pick(backends, nil) == backends[0] {
+			run++
+		} else {
+			run = 0
+		}
+		if run > 2 {
+			t.Fatal("backend 0 got more than two turns in a row")
+		}
+	}
+}
+
+func TestPowerOfTwoNeverPicksTheMostLoaded(t *testing.T) {
+	backends := testBackends([]int{1, 1, 1, 1}, []int64{0, 1, 2, 9})
+	counts := pickCounts(powerOfTwo{}, backends, 1000)
+	if counts[3] != 0 {
+		t.Errorf("most loaded backend picked %d times", counts[3])
+	}
+	if counts[0] == 0 {
+		t.Error("least loaded backend never picked")
+	}
+}
+
+// requestCtx returns a request to path from ip with the given headers
+func requestCtx(app *fiber.App, path, ip string, headers map[string]string) *fiber.Ctx {
+	var req fasthttp.Request
+	req.SetRequestURI(path)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	request := &fasthttp.RequestCtx{}
+	request.Init(&req, &net.TCPAddr{IP: net.ParseIP(ip)}, nil)
+	return app.AcquireCtx(request)
+}
+
+func TestConsistentHashKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		hashKey string
+		path    string
+		headers map[string]string
+		want    string
+	}{
+		{name: "client ip", hashKey: config.HashKeyClientIP, path: "/images/7", want: "ip:1.2.3.4"},
+		{name: "user", hashKey: config.HashKeyUser, path: "/", headers: map[string]string{"X-Username": "ann"}, want: "user:ann"},
+		{name: "api key", hashKey: config.HashKeyUser, path: "/", headers: map[string]string{"Authorization": "Bearer k"}, want: "key:Bearer k"},
+		{name: "anonymous user", hashKey: config.HashKeyUser, path: "/", want: "ip:1.2.3.4"},
+		{name: "image", hashKey: config.HashKeyImageID, path: "/images/7/comments", want: "image:7"},
+		{name: "search is no image", hashKey: config.HashKeyImageID, path: "/images/search", want: "ip:1.2.3.4"},
+		{name: "listing is no image", hashKey: config.HashKeyImageID, path: "/images", want: "ip:1.2.3.4"},
+	}
+
+	app := fiber.New()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := requestCtx(app, test.path, "1.2.3.4", test.headers)
+			defer app.ReleaseCtx(c)
+
+			if got := newConsistentHash(test.hashKey).key(c); got != test.want {
+				t.Errorf("key() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConsistentHashMovesOnlyEjectedKeys(t *testing.T) {
+	app := fiber.New()
+	backends := testBackends([]int{1, 1, 1}, nil)
+	strategy := newConsistentHash(config.HashKeyClientIP)
+
+	pick := func(backends []*Backend, ip string) *Backend {
+		c := requestCtx(app, "/", ip, nil)
+		defer app.ReleaseCtx(c)
+		return strategy.Pick(backends, c)
+	}
+
+	before := make(map[string]*Backend)
+	for i := range 300 {
+		ip := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		before[ip] = pick(backends, ip)
+		if again := pick(backends, ip); again != before[ip] {
+			t.Fatalf("%s moved from %s to %s without a change", ip, before[ip].URL, again.URL)
+		}
+	}
+
+	remaining := backends[:2]
+	for ip, backend := range before {
+		after := pick(remaining, ip)
+		if backend != backends[2] && after != backend {
+			t.Errorf("%s moved from %s to %s though its backend stayed", ip, backend.URL, after.URL)
+		}
+	}
+}
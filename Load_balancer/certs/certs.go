@@ -0,0 +1,129 @@
+// Package certs keeps TLS certificates in memory and reloads them when
+// their files change, so renewed certificates apply without a restart
+package certs
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	shared "SHARED_CONFIG"
+)
+
+// Store holds the certificates of the cert and key files paired by position
+type Store struct {
+	certFiles []string
+	keyFiles  []string
+	certs     atomic.Pointer[[]tls.Certificate]
+	watchers  []*shared.Watcher
+}
+
+// NewStore loads the certificates, failing on the first unreadable pair
+func NewStore(certFiles, keyFiles []string) (*Store, error) {
+	if len(certFiles) == 0 || len(certFiles) != len(keyFiles) {
+		return nil, errors.New("certificates and keys must be paired")
+	}
+
+	store := &Store{certFiles: certFiles, keyFiles: keyFiles}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// load swaps in freshly read certificates, a pair that fails to load keeps
+// every current certificate in place
+func (s *Store) load() error {
+	certs := make([]tls.Certificate, 0, len(s.certFiles))
+	for i, certFile := range s.certFiles {
+		cert, err := tls.LoadX509KeyPair(certFile, s.keyFiles[i])
+		if err != nil {
+			return fmt.Errorf("unable to load certificate %s: %v", certFile, err)
+		}
+
+		// SNI matching needs the parsed leaf
+		if cert.Leaf == nil {
+			if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return fmt.Errorf("unable to parse certificate %s: %v", certFile, err)
+			}
+		}
+		certs = append(certs, cert)
+	}
+
+	s.certs.Store(&certs)
+	return nil
+}
+
+// Watch reloads the certificates on SIGHUP and when a certificate file
+// changes, until Stop
+func (s *Store) Watch(interval time.Duration) {
+	for _, certFile := range s.certFiles {
+		s.watchers = append(s.watchers, shared.Watch(certFile, interval, s.load))
+	}
+}
+
+// Stop ends the reloads
+func (s *Store) Stop() {
+	for _, watcher := range s.watchers {
+		watcher.Stop()
+	}
+}
+
+// GetCertificate serves the certificate matching the requested host name,
+// or the first one
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certs := *s.certs.Load()
+	for i := range certs {
+		if hello.SupportsCertificate(&certs[i]) == nil {
+			return &certs[i], nil
+		}
+	}
+	return &certs[0], nil
+}
+
+// GetClientCertificate presents the first certificate to servers asking for
+// one
+func (s *Store) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return &(*s.certs.Load())[0], nil
+}
+
+// ServerConfig terminates TLS with the store's certificates
+func ServerConfig(store *Store) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: store.GetCertificate,
+	}
+}
+
+// ClientConfig returns the TLS config of connections to backends, and the
+// store of the client certificate when one is configured
+func ClientConfig(settings config.BackendTLS) (*tls.Config, *Store, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if settings.CAFile != "" {
+		pem, err := os.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read backend CA %s: %v", settings.CAFile, err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("no certificates found in backend CA %s", settings.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if settings.CertFile == "" {
+		return tlsConfig, nil, nil
+	}
+	store, err := NewStore([]string{settings.CertFile}, []string{settings.KeyFile})
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig.GetClientCertificate = store.GetClientCertificate
+	return tlsConfig, store, nil
+}
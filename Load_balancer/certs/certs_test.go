@@ -0,0 +1,319 @@
+package certs
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// authority signs the certificates of a test
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string // PEM of cert
+}
+
+func newAuthority(t *testing.T) *authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	writePEM(t, file, "CERTIFICATE", der)
+	return &authority{cert: cert, key: key, file: file}
+}
+
+// issue writes a certificate for the host names and IPs in hosts with its
+// key, returning both files
+func (a *authority) issue(t *testing.T, name string, hosts ...string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, file, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// handshake connects to a TLS server using store with serverName and
+// returns the common name of the certificate it presented
+func handshake(t *testing.T, store *Store, serverName string, roots *x509.CertPool) (string, error) {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	server := tls.Server(serverConn, ServerConfig(store))
+	go func() {
+		defer server.Close()
+		server.Handshake()
+	}()
+
+	client := tls.Client(clientConn, &tls.Config{ServerName: serverName, RootCAs: roots})
+	if err := client.Handshake(); err != nil {
+		return "", err
+	}
+	return client.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestGetCertificateBySNI(t *testing.T) {
+	ca := newAuthority(t)
+	defaultCert, defaultKey := ca.issue(t, "default", "example.com")
+	wildCert, wildKey := ca.issue(t, "wildcard", "*.example.org")
+	apiCert, apiKey := ca.issue(t, "api", "api.example.com", "api.example.net")
+
+	store, err := NewStore([]string{defaultCert, wildCert, apiCert}, []string{defaultKey, wildKey, apiKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	tests := []struct {
+		name       string
+		serverName string
+		want       string
+		wantErr    string
+	}{
+		{name: "first certificate", serverName: "example.com", want: "default"},
+		{name: "exact name", serverName: "api.example.com", want: "api"},
+		{name: "second name", serverName: "api.example.net", want: "api"},
+		{name: "wildcard", serverName: "img.example.org", want: "wildcard"},
+		{name: "case insensitive", serverName: "API.Example.com", want: "api"},
+		{name: "unknown host gets the first certificate", serverName: "other.test", wantErr: "valid for example.com"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := handshake(t, store, test.serverName, roots)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("handshake error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("handshake error = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("certificate = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	ca := newAuthority(t)
+	certFile, keyFile := ca.issue(t, "server", "example.com")
+	_, otherKey := ca.issue(t, "other", "example.net")
+
+	tests := []struct {
+		name      string
+		certFiles []string
+		keyFiles  []string
+		wantErr   string
+	}{
+		{name: "pair", certFiles: []string{certFile}, keyFiles: []string{keyFile}},
+		{name: "none", wantErr: "must be paired"},
+		{name: "unpaired", certFiles: []string{certFile}, keyFiles: []string{keyFile, keyFile}, wantErr: "must be paired"},
+		{name: "missing file", certFiles: []string{certFile + ".missing"}, keyFiles: []string{keyFile}, wantErr: "unable to load certificate"},
+		{name: "mismatched key", certFiles: []string{certFile}, keyFiles: []string{otherKey}, wantErr: "unable to load certificate"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewStore(test.certFiles, test.keyFiles)
+			if test.wantErr == "" && err != nil || test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Errorf("NewStore() error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	ca := newAuthority(t)
+	certFile, keyFile := ca.issue(t, "old", "example.com")
+	store, err := NewStore([]string{certFile}, []string{keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A broken renewal keeps the current certificate
+	os.WriteFile(certFile, []byte("not a certificate"), 0o600)
+	if err := store.load(); err == nil {
+		t.Error("load() of a broken certificate succeeded")
+	}
+	if name := (*store.certs.Load())[0].Leaf.Subject.CommonName; name != "old" {
+		t.Errorf("certificate = %q after a failed reload, want old", name)
+	}
+
+	newCert, newKey := ca.issue(t, "new", "example.com")
+	for from, to := range map[string]string{newCert: certFile, newKey: keyFile} {
+		data, _ := os.ReadFile(from)
+		os.WriteFile(to, data, 0o600)
+	}
+	if err := store.load(); err != nil {
+		t.Fatal(err)
+	}
+	if name := (*store.certs.Load())[0].Leaf.Subject.CommonName; name != "new" {
+		t.Errorf("certificate = %q after the reload, want new", name)
+	}
+}
+
+func TestClientConfigMTLS(t *testing.T) {
+	ca := newAuthority(t)
+	otherCA := newAuthority(t)
+	serverCert, serverKey := ca.issue(t, "backend", "127.0.0.1")
+	clientCert, clientKey := ca.issue(t, "load balancer")
+	strangerCert, strangerKey := otherCA.issue(t, "stranger")
+
+	// The backend requires a client certificate signed by ca
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	backend.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	backend.StartTLS()
+	defer backend.Close()
+
+	tests := []struct {
+		name     string
+		settings config.BackendTLS
+		want     string
+		wantErr  string
+	}{
+		{name: "client certificate", settings: config.BackendTLS{CAFile: ca.file, CertFile: clientCert, KeyFile: clientKey}, want: "load balancer"},
+		{name: "no client certificate", settings: config.BackendTLS{CAFile: ca.file}, wantErr: "certificate required"},
+		{name: "certificate of another CA", settings: config.BackendTLS{CAFile: ca.file, CertFile: strangerCert, KeyFile: strangerKey}, wantErr: "unknown certificate authority"},
+		{name: "backend of another CA", settings: config.BackendTLS{CAFile: otherCA.file, CertFile: clientCert, KeyFile: clientKey}, wantErr: "certificate signed by unknown authority"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tlsConfig, store, err := ClientConfig(test.settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (store != nil) != (test.settings.CertFile != "") {
+				t.Errorf("store = %v with cert_file %q", store, test.settings.CertFile)
+			}
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+			defer client.CloseIdleConnections()
+
+			response, err := client.Get(backend.URL)
+			if test.wantErr != "" {
+				if err == nil {
+					response.Body.Close()
+				}
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("request error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("request error = %v", err)
+			}
+			defer response.Body.Close()
+
+			body := make([]byte, 64)
+			n, _ := response.Body.Read(body)
+			if got := string(body[:n]); got != test.want {
+				t.Errorf("backend saw %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestClientConfigErrors(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+
+	tests := []struct {
+		name     string
+		settings config.BackendTLS
+		wantErr  string
+	}{
+		{name: "missing CA", settings: config.BackendTLS{CAFile: notPEM + ".missing"}, wantErr: "unable to read backend CA"},
+		{name: "CA without certificates", settings: config.BackendTLS{CAFile: notPEM}, wantErr: "no certificates found"},
+		{name: "missing client certificate", settings: config.BackendTLS{CertFile: notPEM + ".missing", KeyFile: notPEM}, wantErr: "unable to load certificate"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := ClientConfig(test.settings); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("ClientConfig() error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	shared "SHARED_CONFIG"
+	"SHARED_CONFIG/forwarding"
+)
+
+// Config is the typed configuration of the load balancer
+type Config struct {
+	HTTP           shared.HTTP    `json:"http" yaml:"http"`
+	Log            shared.Log     `json:"log" yaml:"log"`
+	Tracing        shared.Tracing `json:"tracing" yaml:"tracing"`
+	Metrics        shared.Metrics `json:"metrics" yaml:"metrics"`
+	Backends       []string       `json:"backends" yaml:"backends" env:"BACKENDS"`
+	HealthCheck    HealthCheck    `json:"health_check" yaml:"health_check"`
+	Balancing      Balancing      `json:"balancing" yaml:"balancing"`
+	CircuitBreaker CircuitBreaker `json:"circuit_breaker" yaml:"circuit_breaker"`
+	Retry          Retry          `json:"retry" yaml:"retry"`
+	Admin          Admin          `json:"admin" yaml:"admin"`
+	TLS            TLS            `json:"tls" yaml:"tls"`
+	BackendTLS     BackendTLS     `json:"backend_tls" yaml:"backend_tls"`
+	Forwarding     Forwarding     `json:"forwarding" yaml:"forwarding"`
+}
+
+// Forwarding lists the proxies in front of the load balancer, such as a CDN,
+// whose X-Forwarded-* and Forwarded headers are extended rather than
+// replaced. Loopback is always trusted.
+type Forwarding struct {
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+
+	proxies forwarding.Proxies // Parsed by Load
+}
+
+// Proxies returns the parsed trusted proxies
+func (f Forwarding) Proxies() forwarding.Proxies {
+	return f.proxies
+}
+
+// TLS terminates HTTPS on http.listen_addr when certificates are listed.
+// CertFiles and KeyFiles are paired by position, the certificate matching
+// the requested host name is served and the first one otherwise.
+type TLS struct {
+	CertFiles []string `json:"cert_files" yaml:"cert_files" env:"TLS_CERT_FILES"`
+	KeyFiles  []string `json:"key_files" yaml:"key_files" env:"TLS_KEY_FILES"`
+	// RedirectAddr, when set, answers plain HTTP with a redirect to HTTPS
+	RedirectAddr          string          `json:"redirect_addr" yaml:"redirect_addr" env:"TLS_REDIRECT_ADDR"`
+	HSTSMaxAge            shared.Duration `json:"hsts_max_age" yaml:"hsts_max_age" env:"HSTS_MAX_AGE" default:"8760h"`
+	HSTSIncludeSubdomains bool            `json:"hsts_include_subdomains" yaml:"hsts_include_subdomains" env:"HSTS_INCLUDE_SUBDOMAINS"`
+	// HTTP2 serves HTTP/2 as well as HTTP/1.1, through a net/http front end
+	HTTP2 bool `json:"http2" yaml:"http2" env:"TLS_HTTP2"`
+}
+
+// Enabled reports whether HTTPS is served
+func (t TLS) Enabled() bool {
+	return len(t.CertFiles) > 0
+}
+
+// HSTS returns the Strict-Transport-Security header, empty when disabled
+func (t TLS) HSTS() string {
+	if !t.Enabled() || t.HSTSMaxAge.Std() <= 0 {
+		return ""
+	}
+	header := fmt.Sprintf("max-age=%d", int64(t.HSTSMaxAge.Std().Seconds()))
+	if t.HSTSIncludeSubdomains {
+		header += "; includeSubDomains"
+	}
+	return header
+}
+
+// Validate reports bad TLS settings under prefix
+func (t TLS) Validate(prefix string, p *shared.Problems) {
+	if len(t.CertFiles) != len(t.KeyFiles) {
+		p.Add(prefix+".key_files", "must list one key per certificate, got %d for %d", len(t.KeyFiles), len(t.CertFiles))
+	}
+	if t.RedirectAddr != "" && !t.Enabled() {
+		p.Add(prefix+".redirect_addr", "needs cert_files")
+	}
+	if t.HTTP2 && !t.Enabled() {
+		p.Add(prefix+".http2", "needs cert_files")
+	}
+	if t.HSTSMaxAge.Std() < 0 {
+		p.Add(prefix+".hsts_max_age", "must not be negative, got %s", t.HSTSMaxAge.Std())
+	}
+}
+
+// BackendTLS is how the load balancer connects to https backends. CAFile
+// replaces the system roots, CertFile and KeyFile are presented to backends
+// that require client certificates.
+type BackendTLS struct {
+	CAFile   string `json:"ca_file" yaml:"ca_file" env:"BACKEND_CA_FILE"`
+	CertFile string `json:"cert_file" yaml:"cert_file" env:"BACKEND_CERT_FILE"`
+	KeyFile  string `json:"key_file" yaml:"key_file" env:"BACKEND_KEY_FILE"`
+}
+
+// Validate reports bad backend TLS settings under prefix
+func (b BackendTLS) Validate(prefix string, p *shared.Problems) {
+	if (b.CertFile == "") != (b.KeyFile == "") {
+		p.Add(prefix+".key_file", "cert_file and key_file must be set together")
+	}
+}
+
+// Admin is the backend management API, served on its own address and only
+// when a token is set
+type Admin struct {
+	ListenAddr string `json:"listen_addr" yaml:"listen_addr" env:"ADMIN_LISTEN_ADDR" default:"127.0.0.1:3100"`
+	Token      string `json:"token" yaml:"token" env:"ADMIN_TOKEN"`
+}
+
+// Enabled reports whether the admin API is served
+func (a Admin) Enabled() bool {
+	return a.Token != ""
+}
+
+// Validate reports bad admin settings under prefix
+func (a Admin) Validate(prefix string, p *shared.Problems) {
+	if a.Enabled() {
+		p.Require(prefix+".listen_addr", a.ListenAddr)
+		if len(a.Token) < 16 {
+			p.Add(prefix+".token", "must be at least 16 characters")
+		}
+	}
+}
+
+// CircuitBreaker stops sending requests to a backend after FailureThreshold
+// failed requests in a row, then lets HalfOpenRequests through after
+// OpenTimeout to find out whether it recovered
+type CircuitBreaker struct {
+	FailureThreshold int             `json:"failure_threshold" yaml:"failure_threshold" env:"BREAKER_FAILURE_THRESHOLD" default:"5"`
+	OpenTimeout      shared.Duration `json:"open_timeout" yaml:"open_timeout" env:"BREAKER_OPEN_TIMEOUT" default:"10s"`
+	HalfOpenRequests int             `json:"half_open_requests" yaml:"half_open_requests" env:"BREAKER_HALF_OPEN_REQUESTS" default:"1"`
+}
+
+// Validate reports bad circuit breaker settings under prefix
+func (b CircuitBreaker) Validate(prefix string, p *shared.Problems) {
+	p.Positive(prefix+".failure_threshold", int64(b.FailureThreshold))
+	if b.OpenTimeout.Std() <= 0 {
+		p.Add(prefix+".open_timeout", "must be positive, got %s", b.OpenTimeout.Std())
+	}
+	p.Positive(prefix+".half_open_requests", int64(b.HalfOpenRequests))
+}
+
+// Retry sends failed idempotent requests, and the SafeRequests listed as
+// "METHOD /path", to another backend. Every request adds BudgetRatio to the
+// retry budget, up to BudgetBurst, and every retry takes one from it.
+type Retry struct {
+	MaxAttempts  int      `json:"max_attempts" yaml:"max_attempts" env:"RETRY_MAX_ATTEMPTS" default:"2"`
+	BudgetRatio  float64  `json:"budget_ratio" yaml:"budget_ratio" env:"RETRY_BUDGET_RATIO" default:"0.2"`
+	BudgetBurst  int      `json:"budget_burst" yaml:"budget_burst" env:"RETRY_BUDGET_BURST" default:"20"`
+	SafeRequests []string `json:"safe_requests" yaml:"safe_requests" env:"RETRY_SAFE_REQUESTS" default:"POST /image/listing"`
+}
+
+// Validate reports bad retry settings under prefix
+func (r Retry) Validate(prefix string, p *shared.Problems) {
+	p.Positive(prefix+".max_attempts", int64(r.MaxAttempts))
+	if r.BudgetRatio < 0 || r.BudgetRatio > 1 {
+		p.Add(prefix+".budget_ratio", "must be between 0 and 1, got %v", r.BudgetRatio)
+	}
+	if r.BudgetBurst < 0 {
+		p.Add(prefix+".budget_burst", "must not be negative, got %d", r.BudgetBurst)
+	}
+	for _, request := range r.SafeRequests {
+		method, path, ok := strings.Cut(request, " ")
+		if !ok || method != strings.ToUpper(method) || !strings.HasPrefix(path, "/") {
+			p.Add(prefix+".safe_requests", "%q must look like \"POST /image/listing\"", request)
+		}
+	}
+}
+
+// IsSafe reports whether a request may be sent again, idempotent methods
+// always may
+func (r Retry) IsSafe(method, path string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return slices.Contains(r.SafeRequests, method+" "+path)
+}
+
+// Balancing strategies
+const (
+	StrategyRoundRobin         = "round_robin"
+	StrategyWeightedRoundRobin = "weighted_round_robin"
+	StrategyLeastConnections   = "least_connections"
+	StrategyPowerOfTwo         = "p2c"
+	StrategyConsistentHash     = "consistent_hash"
+)
+
+// Consistent hashing keys
+const (
+	HashKeyClientIP = "client_ip"
+	HashKeyUser     = "user"
+	HashKeyImageID  = "image_id"
+)
+
+// Balancing picks how requests are spread over the backends. Weights are
+// keyed by backend URL, backends without one weigh 1.
+type Balancing struct {
+	Strategy string         `json:"strategy" yaml:"strategy" env:"BALANCING_STRATEGY" default:"round_robin"`
+	HashKey  string         `json:"hash_key" yaml:"hash_key" env:"BALANCING_HASH_KEY" default:"client_ip"`
+	Weights  map[string]int `json:"weights" yaml:"weights"`
+}
+
+// Weight returns the weight of a backend
+func (b Balancing) Weight(backend string) int {
+	if weight, ok := b.Weights[backend]; ok {
+		return weight
+	}
+	return 1
+}
+
+// Validate reports bad balancing settings under prefix
+func (b Balancing) Validate(prefix string, backends []string, p *shared.Problems) {
+	strategies := []string{StrategyRoundRobin, StrategyWeightedRoundRobin, StrategyLeastConnections, StrategyPowerOfTwo, StrategyConsistentHash}
+	if !slices.Contains(strategies, b.Strategy) {
+		p.Add(prefix+".strategy", "must be one of %s, got %q", strings.Join(strategies, ", "), b.Strategy)
+	}
+	hashKeys := []string{HashKeyClientIP, HashKeyUser, HashKeyImageID}
+	if !slices.Contains(hashKeys, b.HashKey) {
+		p.Add(prefix+".hash_key", "must be one of %s, got %q", strings.Join(hashKeys, ", "), b.HashKey)
+	}
+	for backend, weight := range b.Weights {
+		if !slices.Contains(backends, backend) {
+			p.Add(prefix+".weights", "%q is not a listed backend", backend)
+		}
+		if err := ValidateWeight(weight); err != nil {
+			p.Add(prefix+".weights", "%q: %v", backend, err)
+		}
+	}
+}
+
+// HealthCheck tunes the active checks against each backend and the ejection
+// of failing backends
+type HealthCheck struct {
+	Path     string          `json:"path" yaml:"path" env:"HEALTH_CHECK_PATH" default:"/readyz"`
+	Interval shared.Duration `json:"interval" yaml:"interval" env:"HEALTH_CHECK_INTERVAL" default:"2s"`
+	Timeout  shared.Duration `json:"timeout" yaml:"timeout" env:"HEALTH_CHECK_TIMEOUT" default:"1s"`
+	// Consecutive failed checks or proxy errors that eject a backend, and
+	// passed checks that readmit it
+	UnhealthyThreshold int `json:"unhealthy_threshold" yaml:"unhealthy_threshold" env:"HEALTH_CHECK_UNHEALTHY_THRESHOLD" default:"2"`
+	HealthyThreshold   int `json:"healthy_threshold" yaml:"healthy_threshold" env:"HEALTH_CHECK_HEALTHY_THRESHOLD" default:"2"`
+	// A backend is ejected for BaseEjection, doubled on every ejection that
+	// follows shortly after a readmission, up to MaxEjection
+	BaseEjection shared.Duration `json:"base_ejection" yaml:"base_ejection" env:"HEALTH_CHECK_BASE_EJECTION" default:"10s"`
+	MaxEjection  shared.Duration `json:"max_ejection" yaml:"max_ejection" env:"HEALTH_CHECK_MAX_EJECTION" default:"5m"`
+}
+
+// Validate reports bad health check settings under prefix
+func (h HealthCheck) Validate(prefix string, p *shared.Problems) {
+	if !strings.HasPrefix(h.Path, "/") {
+		p.Add(prefix+".path", "must start with /, got %q", h.Path)
+	}
+	if h.Interval.Std() < 100*time.Millisecond {
+		p.Add(prefix+".interval", "must be at least 100ms, got %s", h.Interval.Std())
+	}
+	if h.Timeout.Std() <= 0 || h.Timeout.Std() > h.Interval.Std() {
+		p.Add(prefix+".timeout", "must be positive and at most the interval, got %s", h.Timeout.Std())
+	}
+	p.Positive(prefix+".unhealthy_threshold", int64(h.UnhealthyThreshold))
+	p.Positive(prefix+".healthy_threshold", int64(h.HealthyThreshold))
+	if h.BaseEjection.Std() <= 0 {
+		p.Add(prefix+".base_ejection", "must be positive, got %s", h.BaseEjection.Std())
+	}
+	if h.MaxEjection.Std() < h.BaseEjection.Std() {
+		p.Add(prefix+".max_ejection", "must be at least base_ejection, got %s", h.MaxEjection.Std())
+	}
+}
+
+// Validate reports every bad setting
+func (c *Config) Validate(p *shared.Problems) {
+	c.HTTP.Validate("http", p)
+	c.Log.Validate("log", p)
+	c.Tracing.Validate("tracing", p)
+	c.Metrics.Validate("metrics", p)
+
+	if len(c.Backends) == 0 {
+		p.Add("backends", "must list at least one backend URL")
+	}
+	for _, backend := range c.Backends {
+		if err := ValidateBackend(backend); err != nil {
+			p.Add("backends", "%v", err)
+		}
+	}
+
+	c.HealthCheck.Validate("health_check", p)
+	c.Balancing.Validate("balancing", c.Backends, p)
+	c.CircuitBreaker.Validate("circuit_breaker", p)
+	c.Retry.Validate("retry", p)
+	c.Admin.Validate("admin", p)
+	c.TLS.Validate("tls", p)
+	c.BackendTLS.Validate("backend_tls", p)
+	if _, err := forwarding.ParseProxies(c.Forwarding.TrustedProxies); err != nil {
+		p.Add("forwarding.trusted_proxies", "%v", err)
+	}
+}
+
+// ValidateBackend checks that a backend is an http(s) URL
+func ValidateBackend(backend string) error {
+	u, err := url.Parse(backend)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", backend)
+	}
+	return nil
+}
+
+// ValidateWeight checks that a backend weight is between 1 and 100
+func ValidateWeight(weight int) error {
+	if weight < 1 || weight > 100 {
+		return fmt.Errorf("weight must be between 1 and 100, got %d", weight)
+	}
+	return nil
+}
+
+// Watch reloads the config on SIGHUP and when its file changes, calling
+// onReload with every valid config. The listen addresses, turning the admin
+// API on or off and the TLS files in use need a restart.
+func Watch(path string, current *Config, onReload func(*Config)) *shared.Watcher {
+	return shared.Watch(path, 5*time.Second, func() error {
+		next, err := Load(path)
+		if err != nil {
+			return err
+		}
+		if next.HTTP.ListenAddr != current.HTTP.ListenAddr {
+			slog.Warn("config setting changed, restart to apply it", "field", "http.listen_addr")
+			next.HTTP.ListenAddr = current.HTTP.ListenAddr
+		}
+		if !slices.Equal(next.HTTP.CORSMethods, current.HTTP.CORSMethods) {
+			slog.Warn("config setting changed, restart to apply it", "field", "http.cors_methods")
+			next.HTTP.CORSMethods = current.HTTP.CORSMethods
+		}
+		if next.Metrics != current.Metrics {
+			slog.Warn("config setting changed, restart to apply it", "field", "metrics.listen_addr")
+			next.Metrics = current.Metrics
+		}
+		if next.Admin.ListenAddr != current.Admin.ListenAddr || next.Admin.Enabled() != current.Admin.Enabled() {
+			slog.Warn("config setting changed, restart to apply it", "field", "admin.listen_addr")
+			next.Admin.ListenAddr = current.Admin.ListenAddr
+		}
+		// Certificate files are reloaded when they change, but which files
+		// are used is fixed at startup
+		if !slices.Equal(next.TLS.CertFiles, current.TLS.CertFiles) || !slices.Equal(next.TLS.KeyFiles, current.TLS.KeyFiles) || next.TLS.RedirectAddr != current.TLS.RedirectAddr || next.TLS.HTTP2 != current.TLS.HTTP2 {
+			slog.Warn("config setting changed, restart to apply it", "field", "tls")
+			next.TLS.CertFiles, next.TLS.KeyFiles, next.TLS.RedirectAddr, next.TLS.HTTP2 = current.TLS.CertFiles, current.TLS.KeyFiles, current.TLS.RedirectAddr, current.TLS.HTTP2
+		}
+		if next.BackendTLS != current.BackendTLS {
+			slog.Warn("config setting changed, restart to apply it", "field", "backend_tls")
+			next.BackendTLS = current.BackendTLS
+		}
+
+		current = next
+		onReload(next)
+		return nil
+	})
+}
+
+// Load reads the config from path and the environment, returning every
+// invalid setting at once
+func Load(path string) (*Config, error) {
+	cfg := &Config{
+		HTTP:    shared.HTTP{ListenAddr: "0.0.0.0:3000"},
+		Metrics: shared.Metrics{ListenAddr: "127.0.0.1:3200"},
+	}
+	if err := shared.Load(path, cfg); err != nil {
+		return nil, err
+	}
+
+	// Already validated
+	cfg.Forwarding.proxies, _ = forwarding.ParseProxies(cfg.Forwarding.TrustedProxies)
+	return cfg, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"SHARED_CONFIG/forwarding"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// serveHTTP2 serves HTTP/2 and HTTP/1.1 over TLS on ln with net/http, as
+// fasthttp only speaks HTTP/1.1. Requests are relayed to app on a loopback
+// listener, which is always a trusted proxy, with the client in the
+// forwarding headers.
+func serveHTTP2(app *fiber.App, ln net.Listener, tlsConfig *tls.Config, current *atomic.Pointer[config.Config]) error {
+	internal, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	target := &url.URL{Scheme: "http", Host: internal.Addr().String()}
+
+	tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	server := &http.Server{
+		Handler: &httputil.ReverseProxy{
+			Rewrite: func(r *httputil.ProxyRequest) {
+				r.SetURL(target)
+				r.Out.Host = r.In.Host
+				forwardClient(r, current.Load().Forwarding.Proxies())
+			},
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: 256,
+				IdleConnTimeout:     90 * time.Second,
+				DisableCompression:  true,
+			},
+			// Stream responses as they come
+			FlushInterval: -1,
+			ErrorLog:      slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+		},
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelDebug),
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- server.ServeTLS(ln, "", "")
+	}()
+	go func() {
+		errs <- app.Listener(internal)
+	}()
+
+	// Either side stopping stops the other, app.Shutdown is the usual cause
+	err = <-errs
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = server.Shutdown(ctx)
+	_ = app.Shutdown()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// forwardClient passes the client on like the fasthttp listener does. The
+// proxy strips X-Forwarded-* from the outgoing request, the incoming ones
+// are kept only from a trusted proxy.
+func forwardClient(r *httputil.ProxyRequest, proxies forwarding.Proxies) {
+	host, _, _ := net.SplitHostPort(r.In.RemoteAddr)
+	remote := net.ParseIP(host)
+
+	trusted := proxies.Contains(remote)
+	if trusted {
+		for _, key := range []string{fiber.HeaderXForwardedFor, fiber.HeaderXForwardedProto, fiber.HeaderXForwardedHost} {
+			r.Out.Header[key] = r.In.Header[key]
+		}
+	} else {
+		r.Out.Header.Del("X-Username")
+	}
+	forwarding.Apply(forwarding.HTTPHeader(r.Out.Header), r.In.Host, remote, true, proxies)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"testing"
+
+	"SHARED_CONFIG/forwarding"
+)
+
+func TestForwardClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		remote   string
+		incoming map[string]string
+		want     map[string]string
+	}{
+		{
+			name:     "untrusted client",
+			remote:   "203.0.113.9:5000",
+			incoming: map[string]string{"X-Forwarded-For": "1.1.1.1", "X-Forwarded-Proto": "http", "X-Username": "admin"},
+			want: map[string]string{
+				"X-Forwarded-For":   "203.0.113.9",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "example.com",
+				"Forwarded":         "for=203.0.113.9;proto=https;host=example.com",
+				"X-Username":        "",
+			},
+		},
+		{
+			name:     "trusted proxy",
+			remote:   "10.0.0.1:5000",
+			incoming: map[string]string{"X-Forwarded-For": "198.51.100.7", "X-Forwarded-Host": "cdn.example.com", "X-Username": "ann"},
+			want: map[string]string{
+				"X-Forwarded-For":   "198.51.100.7, 10.0.0.1",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "cdn.example.com",
+				"X-Username":        "ann",
+			},
+		},
+		{
+			name:   "ipv6 client",
+			remote: "[2001:db8::7]:5000",
+			want: map[string]string{
+				"X-Forwarded-For": "2001:db8::7",
+				"Forwarded":       `for="[2001:db8::7]";proto=https;host=example.com`,
+			},
+		},
+	}
+
+	proxies, err := forwarding.ParseProxies([]string{"10.0.0.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			in := httptest.NewRequest(http.MethodGet, "https://example.com/images", nil)
+			in.RemoteAddr = test.remote
+			for key, value := range test.incoming {
+				in.Header.Set(key, value)
+			}
+
+			// The outgoing request starts without forwarding headers, as
+			// ReverseProxy strips them before Rewrite
+			out := in.Clone(in.Context())
+			for _, key := range []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host"} {
+				out.Header.Del(key)
+			}
+
+			forwardClient(&httputil.ProxyRequest{In: in, Out: out}, proxies)
+
+			for key, want := range test.want {
+				if got := out.Header.Get(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
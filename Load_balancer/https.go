@@ -0,0 +1,49 @@
+package main
+
+import (
+	"LOAD_BALANCER_SERVICE/config"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// hsts adds Strict-Transport-Security to every response while HTTPS is
+// served, after proxying so backends cannot drop it
+func hsts(current *atomic.Pointer[config.Config]) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		if header := current.Load().TLS.HSTS(); header != "" {
+			c.Set(fiber.HeaderStrictTransportSecurity, header)
+		}
+		return err
+	}
+}
+
+// httpsRedirect answers plain HTTP requests with a permanent redirect to the
+// same URL on the HTTPS listener
+func httpsRedirect(httpsAddr string) *fiber.App {
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	app.All("/*", func(c *fiber.Ctx) error {
+		host := c.Hostname()
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		target := "https://" + host
+		if port != "443" {
+			target += ":" + port
+		}
+		return c.Redirect(target+c.OriginalURL(), fiber.StatusPermanentRedirect)
+	})
+
+	return app
+}
@@ -1,75 +1,193 @@
 package main
 
 import (
-	"log"
-	"strings"
+	"LOAD_BALANCER_SERVICE/admin"
+	"LOAD_BALANCER_SERVICE/balancer"
+	"LOAD_BALANCER_SERVICE/certs"
+	"LOAD_BALANCER_SERVICE/config"
+	"LOAD_BALANCER_SERVICE/metrics"
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
 	"sync/atomic"
+	"syscall"
+	"time"
+
+	"SHARED_CONFIG/tracing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/proxy"
-	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
 )
 
-type LoadBalancer struct {
-	Backends []string
-	counter  uint32
-}
-
-// NewLoadBalancer initializes a load balancer with backend URLs
-func NewLoadBalancer(backends []string) *LoadBalancer {
-	return &LoadBalancer{Backends: backends}
-}
-
-// GetBackend selects the next backend based on round-robin
-func (lb *LoadBalancer) GetBackend() string {
-	index := atomic.AddUint32(&lb.counter, 1)
-	return lb.Backends[index%uint32(len(lb.Backends))]
-}
-
 func main() {
 
-	// middleware.LoadConfig("config.json")
-
-	backendStr := "https://test.com/"
+	cfg, err := config.Load(configPath())
+	if err != nil {
+		slog.Error("unable to load config", "err", err)
+		os.Exit(1)
+	}
 
-	backends := strings.Split(backendStr, ",")
+	// Log as JSON at the configured level
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.Log.SlogLevel())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel})))
 
-	// Initialize load balancer
-	lb := NewLoadBalancer(backends)
+	shutdownTracing, err := tracing.Setup(context.Background(), "load_balancer", cfg.Tracing)
+	if err != nil {
+		slog.Error("unable to set up tracing", "err", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+	tracer := otel.Tracer("LOAD_BALANCER_SERVICE")
+
+	// Connections to https backends verify them against backend_tls.ca_file
+	// and present the client certificate when one is set
+	backendTLS, clientCerts, err := certs.ClientConfig(cfg.BackendTLS)
+	if err != nil {
+		slog.Error("unable to load backend TLS config", "err", err)
+		os.Exit(1)
+	}
+	if clientCerts != nil {
+		clientCerts.Watch(certReloadInterval)
+		defer clientCerts.Stop()
+	}
+	proxy.WithTlsConfig(backendTLS)
+
+	// Initialize load balancer, failing backends are ejected until their
+	// health checks pass again
+	pool := balancer.NewPool(cfg, backendTLS)
+	pool.StartHealthChecks()
+	defer pool.Stop()
+	budget := balancer.NewRetryBudget(cfg.Retry)
+
+	// Reloads swap the config, read per request
+	var current atomic.Pointer[config.Config]
+	current.Store(cfg)
+
+	// Apply backend, balancing and CORS changes on SIGHUP or when the file changes
+	watcher := config.Watch(configPath(), cfg, func(next *config.Config) {
+		current.Store(next)
+		pool.Configure(next)
+		budget.SetSettings(next.Retry)
+		logLevel.Set(next.Log.SlogLevel())
+	})
+	defer watcher.Stop()
+	metrics.RegisterConfigReloads(watcher.Reloads)
 
-	// Set up Fiber
-	app := fiber.New()
+	// Set up Fiber, bodies over the limit are streamed to the backend rather
+	// than rejected
+	app := fiber.New(fiber.Config{
+		StreamRequestBody: true,
+	})
 
+	app.Use(hsts(&current))
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET, POST",
+		AllowOriginsFunc: func(origin string) bool {
+			return current.Load().HTTP.AllowsOrigin(origin)
+		},
+		AllowMethods: cfg.HTTP.AllowMethods(),
 	}))
 
-	// Middleware to add a unique request ID and log the request
-	app.All("/*", func(c *fiber.Ctx) error {
-		// Generate a unique request ID
-		requestID := uuid.New().String()
+	// Forward everything else to the backends
+	app.All("/*", proxyHandler(pool, budget, &current, tracer))
+
+	// Serve the admin API on its own address, apart from proxied traffic
+	var adminApp *fiber.App
+	if cfg.Admin.Enabled() {
+		adminApp = admin.New(pool, func() string {
+			return current.Load().Admin.Token
+		})
+		go func() {
+			if err := adminApp.Listen(cfg.Admin.ListenAddr); err != nil {
+				slog.Error("unable to start admin API", "err", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// Serve metrics on their own address too
+	var metricsApp *fiber.App
+	if cfg.Metrics.Enabled() {
+		metricsApp = metrics.New()
+		go func() {
+			if err := metricsApp.Listen(cfg.Metrics.ListenAddr); err != nil {
+				slog.Error("unable to start metrics server", "err", err)
+				os.Exit(1)
+			}
+		}()
+	}
 
-		// Store the request ID in the context to pass it to the backend
-		c.Set("X-Request-ID", requestID)
+	// Redirect plain HTTP to HTTPS when asked to
+	var redirectApp *fiber.App
+	if cfg.TLS.RedirectAddr != "" {
+		redirectApp = httpsRedirect(cfg.HTTP.ListenAddr)
+		go func() {
+			if err := redirectApp.Listen(cfg.TLS.RedirectAddr); err != nil {
+				slog.Error("unable to start HTTPS redirect", "err", err)
+				os.Exit(1)
+			}
+		}()
+	}
 
-		// Select the backend
-		backend := lb.GetBackend()
+	// Shut down on SIGTERM/SIGINT so the deferred flushes run
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-sigCh
+		slog.Info("shutting down")
+		for _, other := range []*fiber.App{adminApp, metricsApp, redirectApp} {
+			if other != nil {
+				_ = other.Shutdown()
+			}
+		}
+		_ = app.Shutdown()
+	}()
 
-		// Log the request with ID and target backend
-		log.Printf("Request ID: %s | Forwarding request to: %s", requestID, backend)
+	// Start the load balancer server
+	if err := listen(app, &current); err != nil {
+		slog.Error("unable to start server", "err", err)
+		os.Exit(1)
+	}
+}
 
-		// Use Fiber's proxy middleware to forward the request, including the request ID
-		c.Request().Header.Set("X-Request-ID", requestID)
+// certReloadInterval is how often certificate files are checked for changes
+const certReloadInterval = time.Minute
 
-		// Forward the full URL, including path, to the backend
-		targetURL := backend + c.OriginalURL()
-		return proxy.Do(c, targetURL)
-	})
+// listen serves app on http.listen_addr, over TLS when certificates are
+// configured and with HTTP/2 when tls.http2 is set
+func listen(app *fiber.App, current *atomic.Pointer[config.Config]) error {
+	cfg := current.Load()
+	if !cfg.TLS.Enabled() {
+		return app.Listen(cfg.HTTP.ListenAddr)
+	}
 
-	// Start the load balancer server
-	if err := app.Listen("0.0.0.0:3000"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	store, err := certs.NewStore(cfg.TLS.CertFiles, cfg.TLS.KeyFiles)
+	if err != nil {
+		return err
+	}
+	store.Watch(certReloadInterval)
+	defer store.Stop()
+
+	ln, err := net.Listen("tcp", cfg.HTTP.ListenAddr)
+	if err != nil {
+		return err
+	}
+	if cfg.TLS.HTTP2 {
+		return serveHTTP2(app, ln, certs.ServerConfig(store), current)
+	}
+	return app.Listener(tls.NewListener(ln, certs.ServerConfig(store)))
+}
+
+// configPath is CONFIG_FILE, config.json by default, YAML files are accepted
+// too
+func configPath() string {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
 	}
+	return "config.json"
 }
@@ -0,0 +1,41 @@
+// Package metrics defines the Prometheus metrics of the load balancer, served
+// on metrics.listen_addr apart from proxied traffic
+package metrics
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "uploadkaro"
+	subsystem = "load_balancer"
+)
+
+// RegisterConfigReloads exposes the outcomes counted by a config watcher
+func RegisterConfigReloads(reloads func() (succeeded, failed int64)) {
+	for _, result := range []string{"success", "failure"} {
+		prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "config_reloads_total",
+			Help:        "Config reloads by result.",
+			ConstLabels: prometheus.Labels{"result": result},
+		}, func() float64 {
+			succeeded, failed := reloads()
+			if result == "success" {
+				return float64(succeeded)
+			}
+			return float64(failed)
+		}))
+	}
+}
+
+// New returns the app serving the registered metrics on /metrics
+func New() *fiber.App {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+	return app
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"LOAD_BALANCER_SERVICE/balancer"
+	"LOAD_BALANCER_SERVICE/config"
+	"context"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"SHARED_CONFIG/forwarding"
+	"SHARED_CONFIG/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/proxy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// proxyHandler forwards every request to a backend, tagging it with a
+// request ID and logging it. Failed requests that are safe to repeat are
+// retried on other backends within the retry budget.
+func proxyHandler(pool *balancer.Pool, budget *balancer.RetryBudget, current *atomic.Pointer[config.Config], tracer trace.Tracer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		// Keep the caller's request ID, or start one, and pass it to the
+		// backend. The client gets it back once the response is in.
+		requestID := forwarding.RequestID(c.Get(fiber.HeaderXRequestID))
+		c.Request().Header.Set(fiber.HeaderXRequestID, requestID)
+		defer c.Set(fiber.HeaderXRequestID, requestID)
+
+		// Tell the backend who the client is
+		proxies := current.Load().Forwarding.Proxies()
+		remote := net.IP(c.Context().RemoteIP())
+		clientIP := proxies.ClientIP(remote, c.Get(fiber.HeaderXForwardedFor), c.Get("Forwarded")).String()
+		balancer.SetClientIP(c, clientIP)
+		forwarding.Apply(&c.Request().Header, c.Hostname(), remote, c.Context().IsTLS(), proxies)
+
+		// Only an authenticating proxy in front of us may name the user,
+		// the backends trust the header from the load balancer
+		if !proxies.Contains(remote) {
+			c.Request().Header.Del("X-Username")
+		}
+
+		// Continue the caller's trace
+		ctx := tracing.Extract(c.UserContext(), &c.Request().Header)
+		ctx, serverSpan := tracer.Start(ctx, c.Method()+" proxy", trace.WithSpanKind(trace.SpanKindServer))
+		defer serverSpan.End()
+
+		// Select the backend
+		backend, err := pool.Next(c)
+		if err != nil {
+			slog.Error("request", "request_id", requestID, "method", c.Method(), "path", c.Path(), "client_ip", clientIP, "err", err)
+			return fiber.NewError(fiber.StatusServiceUnavailable, "No healthy backend")
+		}
+
+		// Streamed bodies are read while proxying, they cannot be sent twice
+		retry := budget.Settings()
+		retryable := retry.IsSafe(c.Method(), c.Path()) && !c.Request().IsBodyStream()
+		budget.Deposit()
+
+		tried := []*balancer.Backend{backend}
+		for {
+			err = forward(ctx, c, tracer, pool, backend, requestID, len(tried))
+			if !failed(c, err) || !retryable || len(tried) >= retry.MaxAttempts || !budget.Withdraw() {
+				break
+			}
+
+			next, nextErr := pool.Next(c, tried...)
+			if nextErr != nil {
+				break
+			}
+			backend = next
+			tried = append(tried, backend)
+		}
+
+		// Log the request with ID and target backend
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		if err != nil {
+			level = slog.LevelError
+		}
+		slog.Log(ctx, level, "request",
+			"request_id", requestID,
+			"trace_id", serverSpan.SpanContext().TraceID().String(),
+			"method", c.Method(),
+			"path", c.Path(),
+			"client_ip", clientIP,
+			"backend", backend.URL,
+			"attempts", len(tried),
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+			"err", err,
+		)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadGateway, "Backend unavailable")
+		}
+		return nil
+	}
+}
+
+// forward sends the request to one backend and reports the outcome to the
+// pool. The client span covers the hop and its context goes out as
+// traceparent.
+func forward(ctx context.Context, c *fiber.Ctx, tracer trace.Tracer, pool *balancer.Pool, backend *balancer.Backend, requestID string, attempt int) error {
+	defer backend.Done()
+
+	ctx, span := tracer.Start(ctx, "proxy "+backend.URL, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("server.address", backend.URL),
+			attribute.String("request_id", requestID),
+			attribute.Int("attempt", attempt),
+		))
+	defer span.End()
+	tracing.Inject(ctx, &c.Request().Header)
+
+	// Forward the full URL, including path, to the backend
+	start := time.Now()
+	err := proxy.Do(c, backend.URL+c.OriginalURL())
+	if err != nil {
+		c.Status(fiber.StatusBadGateway)
+	}
+	status := c.Response().StatusCode()
+	pool.Report(backend, err, status, time.Since(start))
+
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "proxy failed")
+	} else if status >= fiber.StatusInternalServerError {
+		span.SetStatus(codes.Error, strconv.Itoa(status))
+	}
+	return err
+}
+
+// failed reports whether the backend could not serve the request, as opposed
+// to answering it with an error of its own
+func failed(c *fiber.Ctx, err error) bool {
+	switch c.Response().StatusCode() {
+	case fiber.StatusBadGateway, fiber.StatusServiceUnavailable, fiber.StatusGatewayTimeout:
+		return true
+	}
+	return err != nil
+}
@@ -0,0 +1,79 @@
+// Package apierror defines the JSON error envelope every failed request gets:
+//
+//	{"error": {"code": "not_found", "message": "Image not found", "request_id": "...", "details": ...}}
+//
+// Handlers return a *fiber.Error for plain failures or an *Error when the
+// client needs machine-readable details. Any other error is internal and
+// reaches the client as a generic 500.
+package apierror
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Error is a client-facing error with optional details
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details any
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// New returns an error whose code is derived from the status
+func New(status int, message string) *Error {
+	return &Error{Status: status, Code: Code(status), Message: message}
+}
+
+// WithDetails attaches details to the error
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+// Body is the JSON shape of an error response
+type Body struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Details   any    `json:"details,omitempty"`
+}
+
+// Envelope wraps Body in the "error" key
+type Envelope struct {
+	Error Body `json:"error"`
+}
+
+var codes = map[int]string{
+	fiber.StatusBadRequest:            "bad_request",
+	fiber.StatusUnauthorized:          "unauthenticated",
+	fiber.StatusForbidden:             "forbidden",
+	fiber.StatusNotFound:              "not_found",
+	fiber.StatusMethodNotAllowed:      "method_not_allowed",
+	fiber.StatusConflict:              "conflict",
+	fiber.StatusGone:                  "gone",
+	fiber.StatusRequestEntityTooLarge: "payload_too_large",
+	fiber.StatusUnsupportedMediaType:  "unsupported_media_type",
+	fiber.StatusUnprocessableEntity:   "unprocessable_entity",
+	fiber.StatusTooManyRequests:       "rate_limited",
+	fiber.StatusInternalServerError:   "internal",
+	fiber.StatusServiceUnavailable:    "unavailable",
+}
+
+// Code returns the stable error code of a status, derived from its text for
+// statuses without a dedicated code
+func Code(status int) string {
+	if code, ok := codes[status]; ok {
+		return code
+	}
+	if text := http.StatusText(status); text != "" {
+		return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+	}
+	return "error"
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"MAIN_SERVER/logging"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	apiKeyPrefix = "uk_"
+	scopesKey    = "auth_scopes"
+
+	// touchInterval is how stale last_used_at may get, a busy key is written
+	// at most this often
+	touchInterval = time.Minute
+)
+
+// lastTouched holds when this instance last updated each key's last_used_at
+var (
+	lastTouched     = make(map[int64]time.Time)
+	lastTouchedLock sync.Mutex
+)
+
+// needsTouch reports whether last_used_at of keyID is due for an update and
+// if so counts it as done
+func needsTouch(keyID int64, now time.Time) bool {
+	lastTouchedLock.Lock()
+	defer lastTouchedLock.Unlock()
+
+	if now.Sub(lastTouched[keyID]) < touchInterval {
+		return false
+	}
+	lastTouched[keyID] = now
+	return true
+}
+
+// NewApiKey generates a key and returns it with its display prefix and the
+// hash to store
+func NewApiKey() (key string, prefix string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:len(apiKeyPrefix)+6], HashApiKey(key), nil
+}
+
+// HashApiKey hashes a key for storage, keys are random enough that a plain
+// SHA-256 cannot be brute-forced
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// identifyApiKey resolves "Authorization: Bearer <key>", ok is false when
+// the header is absent
+func identifyApiKey(c *fiber.Ctx) (ok bool, err error) {
+	token, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !found {
+		return false, nil
+	}
+
+	keyID, userName, scopes, err := postgressqueries.LookupApiKey(c.UserContext(), HashApiKey(strings.TrimSpace(token)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired API key")
+	}
+	if err != nil {
+		return true, err
+	}
+
+	c.Locals(userNameKey, userName)
+	c.Locals(scopesKey, scopes)
+
+	if needsTouch(keyID, time.Now()) {
+		logger := logging.Fiber(c)
+		ctx := context.WithoutCancel(c.UserContext())
+		go func() {
+			if err := postgressqueries.TouchApiKey(ctx, keyID); err != nil {
+				logger.Warn("updating api key last use failed", "api_key_id", keyID, "err", err)
+			}
+		}()
+	}
+	return true, nil
+}
+
+// IsApiKey reports whether the request authenticated with an API key
+func IsApiKey(c *fiber.Ctx) bool {
+	_, ok := c.Locals(scopesKey).([]string)
+	return ok
+}
+
+// RequireScope rejects API key requests lacking scope, other requests pass
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, ok := c.Locals(scopesKey).([]string)
+		if ok && !slices.Contains(scopes, scope) {
+			return fiber.NewError(fiber.StatusForbidden, "API key lacks the "+scope+" scope")
+		}
+		return c.Next()
+	}
+}
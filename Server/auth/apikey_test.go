@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNeedsTouch(t *testing.T) {
+	now := time.Now()
+
+	if !needsTouch(1, now) {
+		t.Fatal("first use of a key was not recorded")
+	}
+	if needsTouch(1, now.Add(touchInterval-time.Second)) {
+		t.Error("key recorded again within the interval")
+	}
+	if !needsTouch(2, now) {
+		t.Error("another key waited for the first one")
+	}
+	if !needsTouch(1, now.Add(touchInterval)) {
+		t.Error("key not recorded again after the interval")
+	}
+}
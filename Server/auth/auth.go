@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"MAIN_SERVER/config"
+	"MAIN_SERVER/logging"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const userNameKey = "auth_username"
+
+// Identify resolves the requester for the current request and stores it in the
+// fiber context, from an API key when one is presented and otherwise from
+// X-Username. The header is only trusted from auth.trusted_proxies, where an
+// authenticating proxy sets it, clients cannot name themselves. Requests
+// without an identity are treated as anonymous.
+func Identify(c *fiber.Ctx) error {
+	if ok, err := identifyApiKey(c); ok {
+		if err != nil {
+			return err
+		}
+		logging.AddFiber(c, "user", UserName(c), "api_key", true)
+		return c.Next()
+	}
+
+	userName := strings.TrimSpace(c.Get("X-Username"))
+	if userName != "" && fromTrustedProxy(c) {
+		c.Locals(userNameKey, userName)
+		logging.AddFiber(c, "user", userName)
+	}
+
+	return c.Next()
+}
+
+// UserName returns the identified requester or an empty string for anonymous
+// requests.
+func UserName(c *fiber.Ctx) string {
+	userName, _ := c.Locals(userNameKey).(string)
+	return userName
+}
+
+// IsAdmin reports whether the requester is listed in auth.admin_users
+func IsAdmin(c *fiber.Ctx) bool {
+	userName := UserName(c)
+	return userName != "" && config.Get().IsAdmin(userName)
+}
+
+// RequireUser rejects anonymous requests. The identity comes from an API key
+// or a trusted proxy, so it is verified once it is set.
+func RequireUser(c *fiber.Ctx) error {
+	if UserName(c) == "" {
+		return fiber.NewError(fiber.StatusUnauthorized, "You must be signed in")
+	}
+	return c.Next()
+}
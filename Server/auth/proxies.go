@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"MAIN_SERVER/config"
+	"log/slog"
+	"net"
+	"sync"
+
+	"SHARED_CONFIG/forwarding"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	trustedProxies     forwarding.Proxies
+	trustedProxiesOnce sync.Once
+)
+
+// TrustedProxies parses auth.trusted_proxies, the IPs and CIDRs of the
+// load balancers in front of the server. Loopback is always trusted.
+func TrustedProxies() forwarding.Proxies {
+	trustedProxiesOnce.Do(func() {
+		var err error
+		trustedProxies, err = forwarding.ParseProxies(config.Get().Auth.TrustedProxies)
+		if err != nil {
+			// Validated with the config, only loopback is left to trust
+			slog.Error("invalid trusted proxies", "err", err)
+			trustedProxies, _ = forwarding.ParseProxies(nil)
+		}
+	})
+	return trustedProxies
+}
+
+// fromTrustedProxy reports whether the connection was opened by a trusted
+// proxy rather than by a client
+func fromTrustedProxy(c *fiber.Ctx) bool {
+	return TrustedProxies().Contains(net.IP(c.Context().RemoteIP()))
+}
@@ -0,0 +1,181 @@
+package album
+
+import (
+	"MAIN_SERVER/auth"
+	"MAIN_SERVER/components/Album/dto"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// albumID parses the :id route parameter
+func albumID(c *fiber.Ctx) (int64, error) {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Invalid album id")
+	}
+	return int64(id), nil
+}
+
+// albumError maps service errors to HTTP errors
+func albumError(err error) error {
+	var invalid *validationError
+
+	switch {
+	case errors.As(err, &invalid):
+		return fiber.NewError(fiber.StatusBadRequest, invalid.Error())
+	case errors.Is(err, postgressqueries.ErrInvalidCursor):
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid cursor")
+	case errors.Is(err, errAlbumNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Album not found")
+	case errors.Is(err, errForbidden):
+		return fiber.NewError(fiber.StatusForbidden, "Only the album owner can do this")
+	}
+
+	return fmt.Errorf("handling album: %w", err)
+}
+
+func createAlbumController(c *fiber.Ctx) error {
+
+	var albumDto dto.AlbumCreateReqDto
+
+	if err := c.BodyParser(&albumDto); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	album, err := createAlbum(c.UserContext(), auth.UserName(c), albumDto)
+	if err != nil {
+		return albumError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(album)
+}
+
+func getAlbumController(c *fiber.Ctx) error {
+
+	id, err := albumID(c)
+	if err != nil {
+		return err
+	}
+
+	album, err := viewAlbum(c.UserContext(), id, auth.UserName(c))
+	if err != nil {
+		return albumError(err)
+	}
+
+	return c.JSON(album)
+}
+
+func updateAlbumController(c *fiber.Ctx) error {
+
+	id, err := albumID(c)
+	if err != nil {
+		return err
+	}
+
+	var patch dto.AlbumUpdateReqDto
+	if err := c.BodyParser(&patch); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	album, err := updateAlbum(c.UserContext(), id, auth.UserName(c), patch)
+	if err != nil {
+		return albumError(err)
+	}
+
+	return c.JSON(album)
+}
+
+func deleteAlbumController(c *fiber.Ctx) error {
+
+	id, err := albumID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteAlbum(c.UserContext(), id, auth.UserName(c)); err != nil {
+		return albumError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func listAlbumImagesController(c *fiber.Ctx) error {
+
+	id, err := albumID(c)
+	if err != nil {
+		return err
+	}
+
+	var query dto.AlbumContentsQueryDto
+	if err := c.QueryParser(&query); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid query parameters")
+	}
+
+	files, nextCursor, err := listAlbumImages(c.UserContext(), id, auth.UserName(c), query)
+	if err != nil {
+		return albumError(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"files":       files,
+		"next_cursor": nextCursor,
+	})
+}
+
+func addAlbumImagesController(c *fiber.Ctx) error {
+
+	id, err := albumID(c)
+	if err != nil {
+		return err
+	}
+
+	var imagesDto dto.AlbumImagesReqDto
+	if err := c.BodyParser(&imagesDto); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	added, err := addAlbumImages(c.UserContext(), id, auth.UserName(c), imagesDto.ImageIDs)
+	if err != nil {
+		return albumError(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"added": added,
+	})
+}
+
+func removeAlbumImageController(c *fiber.Ctx) error {
+
+	id, err := albumID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := removeAlbumImage(c.UserContext(), id, auth.UserName(c), c.Params("imageId")); err != nil {
+		return albumError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func reorderAlbumImagesController(c *fiber.Ctx) error {
+
+	id, err := albumID(c)
+	if err != nil {
+		return err
+	}
+
+	var imagesDto dto.AlbumImagesReqDto
+	if err := c.BodyParser(&imagesDto); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := reorderAlbumImages(c.UserContext(), id, auth.UserName(c), imagesDto.ImageIDs); err != nil {
+		return albumError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
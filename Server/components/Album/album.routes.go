@@ -0,0 +1,27 @@
+package album
+
+import (
+	"MAIN_SERVER/auth"
+	apikeydto "MAIN_SERVER/components/ApiKey/dto"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func Routes(app *fiber.App) {
+	grp := app.Group("/albums")
+
+	// API keys may only call the routes their scopes cover
+	canRead := auth.RequireScope(apikeydto.ScopeRead)
+	canWrite := auth.RequireScope(apikeydto.ScopeWrite)
+
+	grp.Post("/", canWrite, createAlbumController)
+	grp.Get("/:id", canRead, getAlbumController)
+	grp.Patch("/:id", canWrite, updateAlbumController)
+	grp.Delete("/:id", canWrite, deleteAlbumController)
+
+	grp.Get("/:id/images", canRead, listAlbumImagesController)
+	grp.Post("/:id/images", canWrite, addAlbumImagesController)
+	grp.Put("/:id/images/order", canWrite, reorderAlbumImagesController)
+	grp.Delete("/:id/images/:imageId", canWrite, removeAlbumImageController)
+
+}
@@ -0,0 +1,240 @@
+package album
+
+import (
+	"MAIN_SERVER/components/Album/dto"
+	imagedto "MAIN_SERVER/components/Image/dto"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	errAlbumNotFound = errors.New("album not found")
+	errForbidden     = errors.New("forbidden")
+)
+
+// validationError wraps invalid user input so controllers answer 400
+type validationError struct {
+	err error
+}
+
+func (e *validationError) Error() string {
+	return e.err.Error()
+}
+
+// maxAlbumImagesPerRequest bounds the image_ids of a single add or reorder
+const maxAlbumImagesPerRequest = 500
+
+func validateTitle(title string) error {
+	if title == "" {
+		return &validationError{errors.New("title is required")}
+	}
+	if utf8.RuneCountInString(title) > dto.MaxAlbumTitleLength {
+		return &validationError{fmt.Errorf("title must be at most %d characters", dto.MaxAlbumTitleLength)}
+	}
+	return nil
+}
+
+func validateImageIDs(imageIDs []string) error {
+	if len(imageIDs) == 0 {
+		return &validationError{errors.New("image_ids must not be empty")}
+	}
+	if len(imageIDs) > maxAlbumImagesPerRequest {
+		return &validationError{fmt.Errorf("at most %d image_ids are allowed", maxAlbumImagesPerRequest)}
+	}
+	return nil
+}
+
+func getAlbum(ctx context.Context, albumID int64) (*dto.AlbumResponse, error) {
+	album, err := postgressqueries.GetAlbum(ctx, albumID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errAlbumNotFound
+	}
+	return album, err
+}
+
+// viewAlbum returns an album the requester is allowed to see, private albums
+// look missing to everyone but their owner
+func viewAlbum(ctx context.Context, albumID int64, requester string) (*dto.AlbumResponse, error) {
+	album, err := getAlbum(ctx, albumID)
+	if err != nil {
+		return nil, err
+	}
+
+	if album.Visibility == imagedto.VisibilityPrivate && requester != album.Owner {
+		return nil, errAlbumNotFound
+	}
+	return album, nil
+}
+
+// ownAlbum returns an album the requester may change
+func ownAlbum(ctx context.Context, albumID int64, requester string) (*dto.AlbumResponse, error) {
+	album, err := getAlbum(ctx, albumID)
+	if err != nil {
+		return nil, err
+	}
+
+	if requester == "" || requester != album.Owner {
+		return nil, errForbidden
+	}
+	return album, nil
+}
+
+// ErrNotUploadTarget is returned by CheckUploadTarget when the album does not
+// exist or belongs to someone else
+var ErrNotUploadTarget = errors.New("album is not one of the uploader's albums")
+
+// CheckUploadTarget verifies that userName may upload into the album
+func CheckUploadTarget(ctx context.Context, albumID int64, userName string) error {
+	_, err := ownAlbum(ctx, albumID, userName)
+	if errors.Is(err, errAlbumNotFound) || errors.Is(err, errForbidden) {
+		return ErrNotUploadTarget
+	}
+	return err
+}
+
+func createAlbum(ctx context.Context, owner string, req dto.AlbumCreateReqDto) (*dto.AlbumResponse, error) {
+	if owner == "" {
+		return nil, errForbidden
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if err := validateTitle(title); err != nil {
+		return nil, err
+	}
+
+	if req.Visibility == "" {
+		req.Visibility = imagedto.VisibilityPublic
+	}
+	if !imagedto.IsValidVisibility(req.Visibility) {
+		return nil, &validationError{errors.New("visibility must be public, unlisted or private")}
+	}
+
+	if err := checkCover(ctx, owner, req.CoverImageID); err != nil {
+		return nil, err
+	}
+
+	return postgressqueries.CreateAlbum(ctx, owner, title, req.Visibility, req.CoverImageID)
+}
+
+// checkCover verifies that owner may use the image as an album cover, covers
+// are shown with the album so only approved own images qualify. An empty ID
+// means no cover.
+func checkCover(ctx context.Context, owner string, imageID string) error {
+	if imageID == "" {
+		return nil
+	}
+
+	detail, err := postgressqueries.GetImageDetail(ctx, imageID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err != nil || !detail.CanFeature(owner) {
+		return &validationError{errors.New("cover_image_id must be one of your approved, non-private images")}
+	}
+	return nil
+}
+
+func updateAlbum(ctx context.Context, albumID int64, requester string, patch dto.AlbumUpdateReqDto) (*dto.AlbumResponse, error) {
+	album, err := ownAlbum(ctx, albumID, requester)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.Title != nil {
+		title := strings.TrimSpace(*patch.Title)
+		if err := validateTitle(title); err != nil {
+			return nil, err
+		}
+		patch.Title = &title
+	}
+	if patch.Visibility != nil && !imagedto.IsValidVisibility(*patch.Visibility) {
+		return nil, &validationError{errors.New("visibility must be public, unlisted or private")}
+	}
+	if patch.CoverImageID != nil {
+		if err := checkCover(ctx, album.Owner, *patch.CoverImageID); err != nil {
+			return nil, err
+		}
+	}
+
+	err = postgressqueries.UpdateAlbum(ctx, albumID, patch)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errAlbumNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return getAlbum(ctx, albumID)
+}
+
+func deleteAlbum(ctx context.Context, albumID int64, requester string) error {
+	if _, err := ownAlbum(ctx, albumID, requester); err != nil {
+		return err
+	}
+
+	err := postgressqueries.DeleteAlbum(ctx, albumID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errAlbumNotFound
+	}
+	return err
+}
+
+func addAlbumImages(ctx context.Context, albumID int64, requester string, imageIDs []string) (int64, error) {
+	album, err := ownAlbum(ctx, albumID, requester)
+	if err != nil {
+		return 0, err
+	}
+	if err := validateImageIDs(imageIDs); err != nil {
+		return 0, err
+	}
+
+	return postgressqueries.AddAlbumImages(ctx, albumID, album.Owner, imageIDs)
+}
+
+func removeAlbumImage(ctx context.Context, albumID int64, requester string, imageID string) error {
+	if _, err := ownAlbum(ctx, albumID, requester); err != nil {
+		return err
+	}
+
+	err := postgressqueries.RemoveAlbumImage(ctx, albumID, imageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errAlbumNotFound
+	}
+	return err
+}
+
+func reorderAlbumImages(ctx context.Context, albumID int64, requester string, imageIDs []string) error {
+	if _, err := ownAlbum(ctx, albumID, requester); err != nil {
+		return err
+	}
+	if err := validateImageIDs(imageIDs); err != nil {
+		return err
+	}
+
+	ok, err := postgressqueries.ReorderAlbumImages(ctx, albumID, imageIDs)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &validationError{errors.New("image_ids must list every image of the album exactly once")}
+	}
+	return nil
+}
+
+func listAlbumImages(ctx context.Context, albumID int64, requester string, query dto.AlbumContentsQueryDto) ([]imagedto.FileResponse, string, error) {
+	album, err := viewAlbum(ctx, albumID, requester)
+	if err != nil {
+		return nil, "", err
+	}
+
+	owner := ""
+	if requester != "" && requester == album.Owner {
+		owner = requester
+	}
+	return postgressqueries.ListAlbumImages(ctx, albumID, owner, query.Cursor, query.Limit)
+}
@@ -0,0 +1,43 @@
+package dto
+
+import "time"
+
+const MaxAlbumTitleLength = 120
+
+// Albums share the visibility levels of images, see dto.VisibilityPublic in
+// the Image component
+
+type AlbumCreateReqDto struct {
+	Title        string `json:"title"`
+	Visibility   string `json:"visibility"`
+	CoverImageID string `json:"cover_image_id"`
+}
+
+// AlbumUpdateReqDto is the body of PATCH /albums/:id, omitted fields are left
+// unchanged and an empty cover_image_id clears the cover
+type AlbumUpdateReqDto struct {
+	Title        *string `json:"title"`
+	Visibility   *string `json:"visibility"`
+	CoverImageID *string `json:"cover_image_id"`
+}
+
+type AlbumImagesReqDto struct {
+	ImageIDs []string `json:"image_ids"`
+}
+
+type AlbumContentsQueryDto struct {
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit"`
+}
+
+type AlbumResponse struct {
+	ID             int64     `json:"id"`
+	Owner          string    `json:"owner"`
+	Title          string    `json:"title"`
+	Visibility     string    `json:"visibility"`
+	CoverImageID   string    `json:"cover_image_id"`
+	CoverThumbnail string    `json:"cover_thumbnail"`
+	ImageCount     int       `json:"image_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,80 @@
+package apikey
+
+import (
+	"MAIN_SERVER/auth"
+	"MAIN_SERVER/components/ApiKey/dto"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiKeyError maps service errors to HTTP errors
+func apiKeyError(err error) error {
+	var invalid *validationError
+
+	switch {
+	case errors.As(err, &invalid):
+		return fiber.NewError(fiber.StatusBadRequest, invalid.Error())
+	case errors.Is(err, errKeyNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "API key not found")
+	case errors.Is(err, errUnauthenticated):
+		return fiber.NewError(fiber.StatusUnauthorized, "You must be signed in")
+	}
+
+	return fmt.Errorf("handling api key: %w", err)
+}
+
+// rejectApiKeys keeps keys from managing keys, a leaked key must not be able
+// to mint new ones. Keys are only issued to users signed in through the
+// authenticating proxy.
+func rejectApiKeys(c *fiber.Ctx) error {
+	if auth.IsApiKey(c) {
+		return fiber.NewError(fiber.StatusForbidden, "API keys cannot manage API keys")
+	}
+	return c.Next()
+}
+
+func createApiKeyController(c *fiber.Ctx) error {
+
+	var keyDto dto.ApiKeyCreateReqDto
+
+	if err := c.BodyParser(&keyDto); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	key, err := createApiKey(c.UserContext(), auth.UserName(c), keyDto)
+	if err != nil {
+		return apiKeyError(err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusCreated).JSON(key)
+}
+
+func listApiKeysController(c *fiber.Ctx) error {
+
+	keys, err := listApiKeys(c.UserContext(), auth.UserName(c))
+	if err != nil {
+		return apiKeyError(err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.JSON(fiber.Map{
+		"keys": keys,
+	})
+}
+
+func revokeApiKeyController(c *fiber.Ctx) error {
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid api key id")
+	}
+
+	if err := revokeApiKey(c.UserContext(), auth.UserName(c), int64(id)); err != nil {
+		return apiKeyError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,16 @@
+package apikey
+
+import (
+	"MAIN_SERVER/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func Routes(app *fiber.App) {
+	grp := app.Group("/me/api-keys", auth.RequireUser, rejectApiKeys)
+
+	grp.Post("/", createApiKeyController)
+	grp.Get("/", listApiKeysController)
+	grp.Delete("/:id", revokeApiKeyController)
+
+}
@@ -0,0 +1,95 @@
+package apikey
+
+import (
+	"MAIN_SERVER/auth"
+	"MAIN_SERVER/components/ApiKey/dto"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	errKeyNotFound     = errors.New("api key not found")
+	errUnauthenticated = errors.New("unauthenticated")
+)
+
+// validationError wraps invalid user input so controllers answer 400
+type validationError struct {
+	err error
+}
+
+func (e *validationError) Error() string {
+	return e.err.Error()
+}
+
+var validScopes = []string{dto.ScopeUpload, dto.ScopeRead, dto.ScopeWrite, dto.ScopeDelete}
+
+func createApiKey(ctx context.Context, requester string, req dto.ApiKeyCreateReqDto) (*dto.ApiKeyCreatedResponse, error) {
+	if requester == "" {
+		return nil, errUnauthenticated
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > dto.MaxKeyNameLength {
+		return nil, &validationError{fmt.Errorf("name is required and must be at most %d characters", dto.MaxKeyNameLength)}
+	}
+
+	if len(req.Scopes) == 0 {
+		return nil, &validationError{errors.New("at least one scope is required")}
+	}
+	scopes := []string{}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(validScopes, scope) {
+			return nil, &validationError{fmt.Errorf("unknown scope %q, expected one of %s", scope, strings.Join(validScopes, ", "))}
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if req.ExpiresIn < 0 {
+		return nil, &validationError{errors.New("expires_in must not be negative")}
+	}
+	var expiresAt *time.Time
+	if req.ExpiresIn > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+		expiresAt = &t
+	}
+
+	key, prefix, hash, err := auth.NewApiKey()
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := postgressqueries.CreateApiKey(ctx, requester, name, prefix, hash, scopes, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ApiKeyCreatedResponse{ApiKeyResponse: *created, Key: key}, nil
+}
+
+func listApiKeys(ctx context.Context, requester string) ([]dto.ApiKeyResponse, error) {
+	if requester == "" {
+		return nil, errUnauthenticated
+	}
+	return postgressqueries.ListApiKeys(ctx, requester)
+}
+
+func revokeApiKey(ctx context.Context, requester string, keyID int64) error {
+	if requester == "" {
+		return errUnauthenticated
+	}
+
+	err := postgressqueries.RevokeApiKey(ctx, requester, keyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errKeyNotFound
+	}
+	return err
+}
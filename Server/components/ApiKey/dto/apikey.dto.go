@@ -0,0 +1,35 @@
+package dto
+
+import "time"
+
+// Scopes an API key can be granted
+const (
+	ScopeUpload = "upload" // Upload images and edit their metadata
+	ScopeRead   = "read"   // List, search and view images, albums, comments and profiles
+	ScopeWrite  = "write"  // Like, comment, report, share and manage albums and the profile
+	ScopeDelete = "delete" // Delete and restore images
+)
+
+const MaxKeyNameLength = 100
+
+type ApiKeyCreateReqDto struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn int64    `json:"expires_in"` // Seconds until expiry, 0 for none
+}
+
+type ApiKeyResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // First characters of the key, to tell keys apart
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// ApiKeyCreatedResponse is only returned once, on creation
+type ApiKeyCreatedResponse struct {
+	ApiKeyResponse
+	Key string `json:"key"`
+}
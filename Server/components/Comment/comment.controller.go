@@ -0,0 +1,133 @@
+package comment
+
+import (
+	"MAIN_SERVER/auth"
+	"MAIN_SERVER/components/Comment/dto"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// commentID parses the :id route parameter
+func commentID(c *fiber.Ctx) (int64, error) {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Invalid comment id")
+	}
+	return int64(id), nil
+}
+
+// commentError maps service errors to HTTP errors
+func commentError(err error) error {
+	var invalid *validationError
+
+	switch {
+	case errors.As(err, &invalid):
+		return fiber.NewError(fiber.StatusBadRequest, invalid.Error())
+	case errors.Is(err, postgressqueries.ErrInvalidCursor):
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid cursor")
+	case errors.Is(err, errImageNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Image not found")
+	case errors.Is(err, errCommentNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Comment not found")
+	case errors.Is(err, errUnauthenticated):
+		return fiber.NewError(fiber.StatusUnauthorized, "You must be signed in")
+	case errors.Is(err, errForbidden):
+		return fiber.NewError(fiber.StatusForbidden, "Only the author can change this comment")
+	}
+
+	return fmt.Errorf("handling comment: %w", err)
+}
+
+func createCommentController(c *fiber.Ctx) error {
+
+	var commentDto dto.CommentCreateReqDto
+
+	if err := c.BodyParser(&commentDto); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	comment, err := createComment(c.UserContext(), c.Params("id"), auth.UserName(c), commentDto)
+	if err != nil {
+		return commentError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(comment)
+}
+
+func listCommentsController(c *fiber.Ctx) error {
+
+	var query dto.CommentListQueryDto
+
+	// Parse the query string
+	if err := c.QueryParser(&query); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid query parameters")
+	}
+
+	comments, nextCursor, err := listComments(c.UserContext(), c.Params("id"), auth.UserName(c), query)
+	if err != nil {
+		return commentError(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"comments":    comments,
+		"next_cursor": nextCursor,
+	})
+}
+
+func updateCommentController(c *fiber.Ctx) error {
+
+	id, err := commentID(c)
+	if err != nil {
+		return err
+	}
+
+	var commentDto dto.CommentUpdateReqDto
+	if err := c.BodyParser(&commentDto); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	comment, err := updateComment(c.UserContext(), id, auth.UserName(c), commentDto)
+	if err != nil {
+		return commentError(err)
+	}
+
+	return c.JSON(comment)
+}
+
+func deleteCommentController(c *fiber.Ctx) error {
+
+	id, err := commentID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteComment(c.UserContext(), id, auth.UserName(c), auth.IsAdmin(c)); err != nil {
+		return commentError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func reportCommentController(c *fiber.Ctx) error {
+
+	id, err := commentID(c)
+	if err != nil {
+		return err
+	}
+
+	var reportDto dto.ReportReqDto
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&reportDto); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+
+	if err := reportComment(c.UserContext(), id, auth.UserName(c), reportDto); err != nil {
+		return commentError(err)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
@@ -0,0 +1,25 @@
+package comment
+
+import (
+	"MAIN_SERVER/auth"
+	apikeydto "MAIN_SERVER/components/ApiKey/dto"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func Routes(app *fiber.App) {
+
+	// API keys may only call the routes their scopes cover
+	canRead := auth.RequireScope(apikeydto.ScopeRead)
+	canWrite := auth.RequireScope(apikeydto.ScopeWrite)
+
+	app.Get("/images/:id/comments", canRead, listCommentsController)
+	app.Post("/images/:id/comments", canWrite, createCommentController)
+
+	grp := app.Group("/comments")
+
+	grp.Patch("/:id", canWrite, updateCommentController)
+	grp.Delete("/:id", canWrite, deleteCommentController)
+	grp.Post("/:id/report", canWrite, reportCommentController)
+
+}
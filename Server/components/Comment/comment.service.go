@@ -0,0 +1,180 @@
+package comment
+
+import (
+	"MAIN_SERVER/components/Comment/dto"
+	imagedto "MAIN_SERVER/components/Image/dto"
+	"MAIN_SERVER/moderation"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultCommentLimit = 20
+	maxCommentLimit     = 100
+)
+
+var (
+	errImageNotFound   = errors.New("image not found")
+	errCommentNotFound = errors.New("comment not found")
+	errUnauthenticated = errors.New("unauthenticated")
+	errForbidden       = errors.New("forbidden")
+)
+
+// validationError wraps invalid user input so controllers answer 400
+type validationError struct {
+	err error
+}
+
+func (e *validationError) Error() string {
+	return e.err.Error()
+}
+
+// viewableImage checks the requester may see, and so comment on, an image
+func viewableImage(ctx context.Context, imageID string, requester string) error {
+	detail, err := postgressqueries.GetImageDetail(ctx, imageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errImageNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if detail.DeletedAt != nil {
+		return errImageNotFound
+	}
+	isOwner := requester != "" && requester == detail.UploadedBy
+	if !isOwner && (detail.ModerationStatus != "approved" || detail.Visibility == imagedto.VisibilityPrivate) {
+		return errImageNotFound
+	}
+	return nil
+}
+
+// validateBody trims a comment body and runs it through the text filter
+func validateBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", &validationError{errors.New("body is required")}
+	}
+	if utf8.RuneCountInString(body) > dto.MaxCommentLength {
+		return "", &validationError{fmt.Errorf("body must be at most %d characters", dto.MaxCommentLength)}
+	}
+	if err := moderation.GetTextFilter().Check(body); err != nil {
+		return "", &validationError{err}
+	}
+	return body, nil
+}
+
+func createComment(ctx context.Context, imageID string, requester string, req dto.CommentCreateReqDto) (*dto.CommentResponse, error) {
+	if requester == "" {
+		return nil, errUnauthenticated
+	}
+	if err := viewableImage(ctx, imageID, requester); err != nil {
+		return nil, err
+	}
+
+	body, err := validateBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replies go one level deep, on a live comment of the same image
+	if req.ParentID != 0 {
+		parent, err := postgressqueries.GetComment(ctx, req.ParentID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if err != nil || parent.ImageID != imageID || parent.ParentID != 0 || parent.Deleted {
+			return nil, &validationError{errors.New("parent_id must be a top-level comment of this image")}
+		}
+	}
+
+	return postgressqueries.CreateComment(ctx, imageID, req.ParentID, requester, body)
+}
+
+func listComments(ctx context.Context, imageID string, requester string, query dto.CommentListQueryDto) ([]dto.CommentResponse, string, error) {
+	if err := viewableImage(ctx, imageID, requester); err != nil {
+		return nil, "", err
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = defaultCommentLimit
+	}
+	if query.Limit > maxCommentLimit {
+		query.Limit = maxCommentLimit
+	}
+
+	return postgressqueries.ListComments(ctx, imageID, query.Cursor, query.Limit)
+}
+
+// commentAuthor returns the author of a live comment
+func commentAuthor(ctx context.Context, commentID int64) (string, error) {
+	author, err := postgressqueries.GetCommentAuthor(ctx, commentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errCommentNotFound
+	}
+	return author, err
+}
+
+func updateComment(ctx context.Context, commentID int64, requester string, req dto.CommentUpdateReqDto) (*dto.CommentResponse, error) {
+	author, err := commentAuthor(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if requester == "" || requester != author {
+		return nil, errForbidden
+	}
+
+	body, err := validateBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = postgressqueries.UpdateCommentBody(ctx, commentID, body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return postgressqueries.GetComment(ctx, commentID)
+}
+
+// deleteComment soft-deletes a comment, allowed for its author and for
+// moderators
+func deleteComment(ctx context.Context, commentID int64, requester string, isModerator bool) error {
+	author, err := commentAuthor(ctx, commentID)
+	if err != nil {
+		return err
+	}
+	if !isModerator && (requester == "" || requester != author) {
+		return errForbidden
+	}
+
+	err = postgressqueries.SoftDeleteComment(ctx, commentID, requester)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errCommentNotFound
+	}
+	return err
+}
+
+func reportComment(ctx context.Context, commentID int64, requester string, req dto.ReportReqDto) error {
+	if requester == "" {
+		return errUnauthenticated
+	}
+	if _, err := commentAuthor(ctx, commentID); err != nil {
+		return err
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if utf8.RuneCountInString(reason) > dto.MaxReasonLength {
+		return &validationError{fmt.Errorf("reason must be at most %d characters", dto.MaxReasonLength)}
+	}
+
+	return postgressqueries.CreateReport(ctx, "comment", fmt.Sprint(commentID), requester, reason)
+}
@@ -0,0 +1,38 @@
+package dto
+
+import "time"
+
+const (
+	MaxCommentLength = 2000
+	MaxReasonLength  = 500
+)
+
+type CommentCreateReqDto struct {
+	Body     string `json:"body"`
+	ParentID int64  `json:"parent_id"` // Top-level comment to reply to, 0 for none
+}
+
+type CommentUpdateReqDto struct {
+	Body string `json:"body"`
+}
+
+type CommentListQueryDto struct {
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit"`
+}
+
+type ReportReqDto struct {
+	Reason string `json:"reason"`
+}
+
+type CommentResponse struct {
+	ID        int64             `json:"id"`
+	ImageID   string            `json:"image_id"`
+	ParentID  int64             `json:"parent_id,omitempty"`
+	Author    string            `json:"author"`
+	Body      string            `json:"body"` // Empty once deleted
+	CreatedAt time.Time         `json:"created_at"`
+	EditedAt  *time.Time        `json:"edited_at,omitempty"`
+	Deleted   bool              `json:"deleted"`
+	Replies   []CommentResponse `json:"replies,omitempty"`
+}
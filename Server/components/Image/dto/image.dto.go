@@ -1,10 +1,15 @@
 package dto
 
-import "mime/multipart"
+import (
+	"mime/multipart"
+	"time"
+)
 
 type ImageReqDto struct {
-	Images   []*multipart.FileHeader `json:"images"`
-	UserName string                  `json:"username"`
+	Images     []*multipart.FileHeader `json:"images"`
+	UserName   string                  `json:"-" form:"-"`                   // The verified requester
+	AlbumID    int64                   `json:"album" form:"album"`           // Optional album to append the uploads to
+	Visibility string                  `json:"visibility" form:"visibility"` // public (default), unlisted or private
 }
 
 type ImageListingReqDto struct {
@@ -13,14 +18,98 @@ type ImageListingReqDto struct {
 	OrderBy    string `json:"order_by"`
 }
 
+// Visibility levels of images and albums
+const (
+	VisibilityPublic   = "public"   // Listed and viewable by anyone
+	VisibilityUnlisted = "unlisted" // Viewable by anyone with the link
+	VisibilityPrivate  = "private"  // Viewable by the owner only
+)
+
+func IsValidVisibility(visibility string) bool {
+	switch visibility {
+	case VisibilityPublic, VisibilityUnlisted, VisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// ImageListQueryDto holds the query string of GET /images
+type ImageListQueryDto struct {
+	Sort   string `query:"sort"`
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit"`
+	User   string `query:"user"`
+	Tag    string `query:"tag"`
+}
+
+// ImageSearchQueryDto holds the query string of GET /images/search
+type ImageSearchQueryDto struct {
+	Q      string `query:"q"`
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit"`
+}
+
 type FileResponse struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Thumbnail   string `json:"thumbnail"`    // Preview image URL
-	DownloadURL string `json:"download_url"` // Download URL
-	LikedCount  int    `json:"liked_count"`
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Thumbnail    string   `json:"thumbnail"`    // Preview image URL
+	DownloadURL  string   `json:"download_url"` // Download URL
+	LikedCount   int      `json:"liked_count"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	AltText      string   `json:"alt_text"` // Accessible text for the <img> alt attribute
+	Tags         []string `json:"tags"`
+	CommentCount int      `json:"comment_count"`
+	Highlight    string   `json:"highlight,omitempty"` // Escaped HTML snippet with the matches in <mark>, search results only
+
+	ModerationStatus string `json:"moderation_status,omitempty"` // Only shown to the owner
+}
+
+// Length limits of the user-editable metadata, in characters
+const (
+	MaxTitleLength       = 120
+	MaxDescriptionLength = 2000
+	MaxAltTextLength     = 250
+)
+
+// ImageMetadata is the data supplied alongside each file at upload time
+type ImageMetadata struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	AltText     string   `json:"alt_text"`
+	Tags        []string `json:"tags"`     // Normalized tags
+	AlbumID     int64    `json:"album_id"` // Album to append the image to, 0 for none
+	Visibility  string   `json:"visibility"`
+}
+
+// ImageMetadataPatchDto is the body of PATCH /images/:id, omitted fields are
+// left unchanged
+type ImageMetadataPatchDto struct {
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+	AltText     *string   `json:"alt_text"`
+	Tags        *[]string `json:"tags"` // Replaces the whole tag set
+	Visibility  *string   `json:"visibility"`
 }
 
 type ImageLikeReqDto struct {
 	ImageID string `json:"image_id"`
 }
+
+// ImageDetail is the full metadata returned by GET /images/:id
+type ImageDetail struct {
+	FileResponse
+	UploadedBy string     `json:"uploaded_by"`
+	Width      int        `json:"width"`
+	Height     int        `json:"height"`
+	SizeBytes  int64      `json:"size_bytes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"` // Set while the image awaits purging
+	Visibility string     `json:"visibility"`
+}
+
+// CanFeature reports whether owner may show the image publicly, as an avatar
+// or album cover: it must be their own approved, non-private, live image
+func (d *ImageDetail) CanFeature(owner string) bool {
+	return d.UploadedBy == owner && d.DeletedAt == nil && d.ModerationStatus == "approved" && d.Visibility != VisibilityPrivate
+}
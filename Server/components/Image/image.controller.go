@@ -1,8 +1,18 @@
 package image
 
 import (
+	"MAIN_SERVER/apierror"
+	"MAIN_SERVER/auth"
+	album "MAIN_SERVER/components/Album"
+	commentdto "MAIN_SERVER/components/Comment/dto"
 	"MAIN_SERVER/components/Image/dto"
+	tag "MAIN_SERVER/components/Tag"
+	"MAIN_SERVER/config"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"MAIN_SERVER/quota"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,16 +23,17 @@ func uploadImageController(c *fiber.Ctx) error {
 
 	// Parse the form fields and files
 	if err := c.BodyParser(&imageDto); err != nil {
-		fmt.Println("Error parsing request body:", err)
-		return err
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
+	// Uploads belong to the verified requester, never to a name in the form
+	imageDto.UserName = auth.UserName(c)
+
 	// Retrieve the uploaded images using FormFile method
 	// Fiber allows you to get files via `c.FormFile(fieldName)`
 	files, err := c.MultipartForm()
 	if err != nil {
-		fmt.Println("Error retrieving multipart form:", err)
-		return err
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid multipart form")
 	}
 
 	// Assuming images are under the field name "images"
@@ -31,30 +42,110 @@ func uploadImageController(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "No images uploaded")
 	}
 
+	// Check the batch against the upload policy
+	policy := config.Get().Uploads
+	if len(imageFiles) > policy.MaxFiles {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("At most %d images can be uploaded at once", policy.MaxFiles))
+	}
+	for _, file := range imageFiles {
+		if file.Size > policy.MaxFileBytes {
+			return fiber.NewError(fiber.StatusRequestEntityTooLarge, fmt.Sprintf("%s is larger than %d bytes", file.Filename, policy.MaxFileBytes))
+		}
+		if !policy.AllowsType(file.Header.Get(fiber.HeaderContentType)) {
+			return fiber.NewError(fiber.StatusUnsupportedMediaType, fmt.Sprintf("%s must be one of %s", file.Filename, strings.Join(policy.AllowedTypes, ", ")))
+		}
+	}
+
+	if imageDto.Visibility == "" {
+		imageDto.Visibility = dto.VisibilityPublic
+	}
+	if !dto.IsValidVisibility(imageDto.Visibility) {
+		return fiber.NewError(fiber.StatusBadRequest, "visibility must be public, unlisted or private")
+	}
+
+	// Hold the quota before enqueuing, the workers release it
+	var batchBytes int64
+	for _, file := range imageFiles {
+		batchBytes += file.Size
+	}
+	if err := quota.Reserve(c.UserContext(), imageDto.UserName, batchBytes, len(imageFiles)); err != nil {
+		var exceeded *quota.ExceededError
+		if errors.As(err, &exceeded) {
+			return apierror.New(fiber.StatusRequestEntityTooLarge, exceeded.Error()).WithDetails(exceeded.Usage)
+		}
+		return fmt.Errorf("checking quota: %w", err)
+	}
+	reserved := true
+	defer func() {
+		if reserved {
+			quota.Release(imageDto.UserName, batchBytes, len(imageFiles))
+		}
+	}()
+
+	// Uploads may target one of the uploader's albums
+	if imageDto.AlbumID != 0 {
+		err := album.CheckUploadTarget(c.UserContext(), imageDto.AlbumID, imageDto.UserName)
+		if errors.Is(err, album.ErrNotUploadTarget) {
+			return fiber.NewError(fiber.StatusBadRequest, "album must be one of your albums")
+		}
+		if err != nil {
+			return fmt.Errorf("checking album: %w", err)
+		}
+	}
+
+	// Captions are optional repeated fields aligned with "images" by position
+	metadata := make([]dto.ImageMetadata, len(imageFiles))
+	for i := range imageFiles {
+		metadata[i] = dto.ImageMetadata{
+			Title:       formValueAt(files.Value["title"], i),
+			Description: formValueAt(files.Value["description"], i),
+			AltText:     formValueAt(files.Value["alt_text"], i),
+			AlbumID:     imageDto.AlbumID,
+			Visibility:  imageDto.Visibility,
+		}
+		if err := validateMetadata(metadata[i].Title, metadata[i].Description, metadata[i].AltText); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%s: %v", imageFiles[i].Filename, err))
+		}
+
+		// Tags are comma-separated within each file's "tags" value
+		metadata[i].Tags, err = tag.NormalizeList(tag.SplitList(formValueAt(files.Value["tags"], i)))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%s: %v", imageFiles[i].Filename, err))
+		}
+	}
+
 	// Send the image upload task to a background worker
+	reserved = false
+	ctx := c.UserContext()
 	go func() {
-		uploadImages(imageFiles, imageDto.UserName)
+		uploadImages(ctx, imageFiles, imageDto.UserName, metadata)
 	}()
 
 	// Immediately respond to the client that the images are being uploaded
 	return c.SendString("Images upload started successfully, processing in the background.")
 }
 
+// formValueAt returns the trimmed i-th value of a repeated form field
+func formValueAt(values []string, i int) string {
+	if i >= len(values) {
+		return ""
+	}
+	return strings.TrimSpace(values[i])
+}
+
 func listingImageController(c *fiber.Ctx) error {
 
 	var ImageListingReqDto dto.ImageListingReqDto
 
 	// Parse the form fields and files
 	if err := c.BodyParser(&ImageListingReqDto); err != nil {
-		fmt.Println("Error parsing request body:", err)
-		return err
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
 	// Retrieve the uploaded images using FormFile method
-	files, totalPages, err := ListImages(ImageListingReqDto.PageNumber, ImageListingReqDto.PageSize, ImageListingReqDto.OrderBy)
+	files, totalPages, err := ListImages(c.UserContext(), ImageListingReqDto.PageNumber, ImageListingReqDto.PageSize, ImageListingReqDto.OrderBy)
 	if err != nil {
-		fmt.Println("Error retrieving multipart form:", err)
-		return err
+		return fmt.Errorf("listing images: %w", err)
 	}
 
 	// Return the files as a JSON response, including the nextPageToken for pagination
@@ -64,20 +155,172 @@ func listingImageController(c *fiber.Ctx) error {
 	})
 }
 
+func listImagesController(c *fiber.Ctx) error {
+
+	var query dto.ImageListQueryDto
+
+	// Parse the query string
+	if err := c.QueryParser(&query); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid query parameters")
+	}
+
+	files, nextCursor, err := listImagesByCursor(c.UserContext(), query)
+	if errors.Is(err, postgressqueries.ErrInvalidCursor) {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid cursor")
+	}
+	if err != nil {
+		return fmt.Errorf("listing images: %w", err)
+	}
+
+	// Listings are public, let browsers and proxies keep them briefly
+	c.Set(fiber.HeaderCacheControl, "public, max-age=30")
+
+	return c.JSON(fiber.Map{
+		"files":       files,
+		"next_cursor": nextCursor,
+	})
+}
+
+func searchImagesController(c *fiber.Ctx) error {
+
+	var query dto.ImageSearchQueryDto
+
+	// Parse the query string
+	if err := c.QueryParser(&query); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid query parameters")
+	}
+
+	files, nextCursor, err := searchImages(c.UserContext(), query)
+	var invalid *validationError
+	switch {
+	case errors.As(err, &invalid):
+		return fiber.NewError(fiber.StatusBadRequest, invalid.Error())
+	case errors.Is(err, postgressqueries.ErrInvalidCursor):
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid cursor")
+	case err != nil:
+		return fmt.Errorf("searching images: %w", err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=30")
+
+	return c.JSON(fiber.Map{
+		"files":       files,
+		"next_cursor": nextCursor,
+	})
+}
+
+func imageDetailController(c *fiber.Ctx) error {
+
+	requester := auth.UserName(c)
+
+	detail, err := getImageDetail(c.UserContext(), c.Params("id"), requester)
+	if errors.Is(err, errImageNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, "Image not found")
+	}
+	if err != nil {
+		return fmt.Errorf("fetching image: %w", err)
+	}
+
+	// The owner's view, identified by X-Username or an API key, includes the
+	// moderation status and must not be shared, neither may images kept out
+	// of public listings
+	c.Vary("X-Username", fiber.HeaderAuthorization)
+	if detail.ModerationStatus != "" || detail.Visibility != dto.VisibilityPublic {
+		c.Set(fiber.HeaderCacheControl, "private, no-cache")
+	} else {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+	}
+
+	return c.JSON(detail)
+}
+
+func deleteImageController(c *fiber.Ctx) error {
+
+	restorableUntil, err := deleteImage(c.UserContext(), c.Params("id"), auth.UserName(c), auth.IsAdmin(c))
+	if err != nil {
+		return imageChangeError(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"image_id":         c.Params("id"),
+		"restorable_until": restorableUntil,
+	})
+}
+
+func updateImageMetadataController(c *fiber.Ctx) error {
+
+	var patch dto.ImageMetadataPatchDto
+
+	if err := c.BodyParser(&patch); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	detail, err := updateImageMetadata(c.UserContext(), c.Params("id"), auth.UserName(c), auth.IsAdmin(c), patch)
+	if err != nil {
+		return imageChangeError(err)
+	}
+
+	return c.JSON(detail)
+}
+
+func restoreImageController(c *fiber.Ctx) error {
+
+	if err := restoreImage(c.UserContext(), c.Params("id"), auth.UserName(c), auth.IsAdmin(c)); err != nil {
+		return imageChangeError(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"image_id": c.Params("id"),
+		"restored": true,
+	})
+}
+
+func reportImageController(c *fiber.Ctx) error {
+
+	var reportDto commentdto.ReportReqDto
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&reportDto); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+
+	if err := reportImage(c.UserContext(), c.Params("id"), auth.UserName(c), reportDto.Reason); err != nil {
+		return imageChangeError(err)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// imageChangeError maps service errors of owner-only operations to HTTP errors
+func imageChangeError(err error) error {
+	var invalid *validationError
+
+	switch {
+	case errors.As(err, &invalid):
+		return fiber.NewError(fiber.StatusBadRequest, invalid.Error())
+	case errors.Is(err, errImageNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Image not found")
+	case errors.Is(err, errUnauthenticated):
+		return fiber.NewError(fiber.StatusUnauthorized, "You must be signed in")
+	case errors.Is(err, errForbidden):
+		return fiber.NewError(fiber.StatusForbidden, "Only the owner or an admin can change this image")
+	}
+
+	return fmt.Errorf("changing image: %w", err)
+}
+
 func likeImageController(c *fiber.Ctx) error {
 
 	var imageLikeDto dto.ImageLikeReqDto
 
 	// Parse the form fields and files
 	if err := c.BodyParser(&imageLikeDto); err != nil {
-		fmt.Println("Error parsing request body:", err)
-		return err
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
-	likeCount, err := likeImage(imageLikeDto.ImageID)
+	likeCount, err := likeImage(c.UserContext(), imageLikeDto.ImageID)
 	if err != nil {
-		fmt.Println("Error Liking image :", err)
-		return err
+		return fmt.Errorf("liking image: %w", err)
 	}
 
 	return c.JSON(fiber.Map{
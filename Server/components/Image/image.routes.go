@@ -1,12 +1,38 @@
 package image
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"MAIN_SERVER/auth"
+	apikeydto "MAIN_SERVER/components/ApiKey/dto"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/etag"
+)
 
 func Routes(app *fiber.App) {
 	grp := app.Group("/image")
 
-	grp.Post("/upload", uploadImageController)
-	grp.Post("/listing", listingImageController)
-	grp.Post("/like", likeImageController)
+	// API keys may only call the routes their scopes cover
+	canUpload := auth.RequireScope(apikeydto.ScopeUpload)
+	canRead := auth.RequireScope(apikeydto.ScopeRead)
+	canWrite := auth.RequireScope(apikeydto.ScopeWrite)
+	canDelete := auth.RequireScope(apikeydto.ScopeDelete)
+
+	// Uploads count against the uploader's quota, so they need a verified user
+	grp.Post("/upload", auth.RequireUser, canUpload, uploadImageController)
+	grp.Post("/listing", canRead, listingImageController)
+	grp.Post("/like", canWrite, likeImageController)
+
+	// RESTful read endpoints, answering If-None-Match with 304
+	cacheable := etag.New()
+
+	images := app.Group("/images")
+
+	images.Get("/", canRead, cacheable, listImagesController)
+	images.Get("/search", canRead, cacheable, searchImagesController)
+	images.Get("/:id", canRead, cacheable, imageDetailController)
+	images.Patch("/:id", canUpload, updateImageMetadataController)
+	images.Delete("/:id", canDelete, deleteImageController)
+	images.Post("/:id/restore", canDelete, restoreImageController)
+	images.Post("/:id/report", canWrite, reportImageController)
 
 }
@@ -1,36 +1,221 @@
 package image
 
 import (
+	commentdto "MAIN_SERVER/components/Comment/dto"
 	"MAIN_SERVER/components/Image/dto"
+	tag "MAIN_SERVER/components/Tag"
 	postgressqueries "MAIN_SERVER/postgress/queries"
+	"MAIN_SERVER/reaper"
 	worker "MAIN_SERVER/workerpool"
-	"log"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"mime/multipart"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
-func uploadImages(image []*multipart.FileHeader, userName string) error {
+// errImageNotFound is returned for missing images and for unapproved images
+// requested by someone other than their owner
+var errImageNotFound = errors.New("image not found")
+
+// errForbidden is returned when the requester may not change an image
+var errForbidden = errors.New("forbidden")
+
+// errUnauthenticated is returned when an anonymous requester needs a name
+var errUnauthenticated = errors.New("unauthenticated")
+
+// validationError wraps invalid user input so controllers answer 400
+type validationError struct {
+	err error
+}
+
+func (e *validationError) Error() string {
+	return e.err.Error()
+}
+
+// uploadImages queues the files for the upload workers, which log the outcome
+// of each one with the logger carried by ctx
+func uploadImages(ctx context.Context, image []*multipart.FileHeader, userName string, metadata []dto.ImageMetadata) {
 
 	// Add images to the task queue
-	for _, file := range image {
+	for i, file := range image {
 		task := &worker.Task{
+			Ctx:      ctx,
 			File:     file,
 			UserName: userName,
+			Metadata: metadata[i],
 		}
 		worker.TaskChan <- task
 	}
+}
+func ListImages(ctx context.Context, pageNumber int, pageSize int, orderBy string) ([]dto.FileResponse, int, error) {
+	return postgressqueries.ListImages(ctx, pageNumber, pageSize, orderBy)
+}
 
-	for err := range worker.ErrChan {
-		if err != nil {
-			log.Printf("Error: %v", err)
+func listImagesByCursor(ctx context.Context, query dto.ImageListQueryDto) ([]dto.FileResponse, string, error) {
+	query.Tag = tag.Normalize(query.Tag)
+	return postgressqueries.ListImagesByCursor(ctx, query)
+}
+
+func searchImages(ctx context.Context, query dto.ImageSearchQueryDto) ([]dto.FileResponse, string, error) {
+	tsQuery := postgressqueries.BuildPrefixQuery(query.Q)
+	if tsQuery == "" {
+		return nil, "", &validationError{errors.New("q must contain at least one word")}
+	}
+
+	return postgressqueries.SearchImages(ctx, tsQuery, query.Cursor, query.Limit)
+}
+
+func getImageDetail(ctx context.Context, imageID string, requester string) (*dto.ImageDetail, error) {
+	detail, err := postgressqueries.GetImageDetail(ctx, imageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errImageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Moderation outcomes, deleted and private images are only visible to the
+	// uploader
+	isOwner := requester != "" && requester == detail.UploadedBy
+	if !isOwner {
+		if detail.ModerationStatus != "approved" || detail.DeletedAt != nil || detail.Visibility == dto.VisibilityPrivate {
+			return nil, errImageNotFound
 		}
+		detail.ModerationStatus = ""
+	}
+
+	return detail, nil
+}
+
+// authorizeImageChange loads an image and checks the requester owns it,
+// admins may change any image
+func authorizeImageChange(ctx context.Context, imageID string, requester string, isAdmin bool) (*dto.ImageDetail, error) {
+	detail, err := postgressqueries.GetImageDetail(ctx, imageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errImageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAdmin && (requester == "" || requester != detail.UploadedBy) {
+		return nil, errForbidden
 	}
+	return detail, nil
+}
+
+func deleteImage(ctx context.Context, imageID string, requester string, isAdmin bool) (time.Time, error) {
+	if _, err := authorizeImageChange(ctx, imageID, requester, isAdmin); err != nil {
+		return time.Time{}, err
+	}
+
+	err := postgressqueries.SoftDeleteImage(ctx, imageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, errImageNotFound
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Now().Add(reaper.Retention()), nil
+}
 
+func restoreImage(ctx context.Context, imageID string, requester string, isAdmin bool) error {
+	if _, err := authorizeImageChange(ctx, imageID, requester, isAdmin); err != nil {
+		return err
+	}
+
+	err := postgressqueries.RestoreImage(ctx, imageID, reaper.Retention())
+	if errors.Is(err, sql.ErrNoRows) {
+		return errImageNotFound
+	}
+	return err
+}
+
+// validateMetadata checks the length limits of the user-editable metadata
+func validateMetadata(title string, description string, altText string) error {
+	limits := []struct {
+		field string
+		value string
+		max   int
+	}{
+		{"title", title, dto.MaxTitleLength},
+		{"description", description, dto.MaxDescriptionLength},
+		{"alt_text", altText, dto.MaxAltTextLength},
+	}
+
+	for _, limit := range limits {
+		if utf8.RuneCountInString(limit.value) > limit.max {
+			return fmt.Errorf("%s must be at most %d characters", limit.field, limit.max)
+		}
+	}
 	return nil
 }
-func ListImages(pageNumber int, pageSize int, orderBy string) ([]dto.FileResponse, int, error) {
-	return postgressqueries.ListImages(pageNumber, pageSize, orderBy)
+
+func updateImageMetadata(ctx context.Context, imageID string, requester string, isAdmin bool, patch dto.ImageMetadataPatchDto) (*dto.ImageDetail, error) {
+	if _, err := authorizeImageChange(ctx, imageID, requester, isAdmin); err != nil {
+		return nil, err
+	}
+
+	// Trim and validate the fields being changed
+	for _, field := range []*string{patch.Title, patch.Description, patch.AltText} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+	if err := validateMetadata(deref(patch.Title), deref(patch.Description), deref(patch.AltText)); err != nil {
+		return nil, &validationError{err}
+	}
+	if patch.Visibility != nil && !dto.IsValidVisibility(*patch.Visibility) {
+		return nil, &validationError{errors.New("visibility must be public, unlisted or private")}
+	}
+	if patch.Tags != nil {
+		tags, err := tag.NormalizeList(*patch.Tags)
+		if err != nil {
+			return nil, &validationError{err}
+		}
+		patch.Tags = &tags
+	}
+
+	err := postgressqueries.UpdateImageMetadata(ctx, imageID, patch)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errImageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return postgressqueries.GetImageDetail(ctx, imageID)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// reportImage records a user report of a visible image for moderators
+func reportImage(ctx context.Context, imageID string, requester string, reason string) error {
+	if requester == "" {
+		return errUnauthenticated
+	}
+	if _, err := getImageDetail(ctx, imageID, requester); err != nil {
+		return err
+	}
+
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > commentdto.MaxReasonLength {
+		return &validationError{fmt.Errorf("reason must be at most %d characters", commentdto.MaxReasonLength)}
+	}
+
+	return postgressqueries.CreateReport(ctx, "image", imageID, requester, reason)
 }
 
-func likeImage(imageID string) (int, error) {
-	return postgressqueries.GetLikeCache().LikeImage(imageID)
+func likeImage(ctx context.Context, imageID string) (int, error) {
+	return postgressqueries.GetLikeCache().LikeImage(ctx, imageID)
 }
@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+// ShareCreateReqDto is the body of POST /images/:id/share, every field is
+// optional
+type ShareCreateReqDto struct {
+	ExpiresIn int64  `json:"expires_in"` // Seconds until the link expires, defaults to 7 days
+	Password  string `json:"password"`
+	MaxViews  int    `json:"max_views"` // 0 for unlimited
+}
+
+type ShareResponse struct {
+	Token             string    `json:"token"`
+	URL               string    `json:"url"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	MaxViews          int       `json:"max_views,omitempty"`
+	PasswordProtected bool      `json:"password_protected"`
+}
+
+// ShareLink is a stored share link, the token is derived from ID
+type ShareLink struct {
+	ID           string
+	ImageID      string
+	CreatedBy    string
+	ExpiresAt    time.Time
+	PasswordHash string
+	MaxViews     int
+	ViewCount    int
+}
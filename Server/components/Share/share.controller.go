@@ -0,0 +1,79 @@
+package share
+
+import (
+	"MAIN_SERVER/auth"
+	"MAIN_SERVER/components/Share/dto"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// shareError maps service errors to HTTP errors
+func shareError(err error) error {
+	var invalid *validationError
+
+	switch {
+	case errors.As(err, &invalid):
+		return fiber.NewError(fiber.StatusBadRequest, invalid.Error())
+	case errors.Is(err, errImageNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Image not found")
+	case errors.Is(err, errForbidden):
+		return fiber.NewError(fiber.StatusForbidden, "Only the owner can share this image")
+	case errors.Is(err, errLinkNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Share link not found")
+	case errors.Is(err, errLinkExpired):
+		return fiber.NewError(fiber.StatusGone, "Share link has expired")
+	case errors.Is(err, errWrongPassword):
+		return fiber.NewError(fiber.StatusUnauthorized, "A valid password is required")
+	}
+
+	return fmt.Errorf("handling share link: %w", err)
+}
+
+func createShareController(c *fiber.Ctx) error {
+
+	var shareDto dto.ShareCreateReqDto
+
+	// An empty body creates a link with the defaults
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&shareDto); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+
+	share, err := createShare(c.UserContext(), c.Params("id"), auth.UserName(c), shareDto)
+	if err != nil {
+		return shareError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(share)
+}
+
+func revokeShareController(c *fiber.Ctx) error {
+
+	if err := revokeShare(c.UserContext(), c.Params("token"), auth.UserName(c)); err != nil {
+		return shareError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func resolveShareController(c *fiber.Ctx) error {
+
+	// Prefer the header so passwords stay out of access logs
+	password := c.Get("X-Share-Password")
+	if password == "" {
+		password = c.Query("password")
+	}
+
+	detail, err := resolveShare(c.UserContext(), c.Params("token"), password)
+	if err != nil {
+		return shareError(err)
+	}
+
+	// Every hit counts as a view, never serve it from a cache
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+
+	return c.JSON(detail)
+}
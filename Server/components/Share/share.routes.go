@@ -0,0 +1,17 @@
+package share
+
+import (
+	"MAIN_SERVER/auth"
+	apikeydto "MAIN_SERVER/components/ApiKey/dto"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func Routes(app *fiber.App) {
+
+	// API keys may only call the routes their scopes cover
+	app.Post("/images/:id/share", auth.RequireScope(apikeydto.ScopeWrite), createShareController)
+	app.Get("/s/:token", auth.RequireScope(apikeydto.ScopeRead), resolveShareController)
+	app.Delete("/s/:token", auth.RequireUser, auth.RequireScope(apikeydto.ScopeWrite), revokeShareController)
+
+}
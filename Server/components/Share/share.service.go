@@ -0,0 +1,158 @@
+package share
+
+import (
+	imagedto "MAIN_SERVER/components/Image/dto"
+	"MAIN_SERVER/components/Share/dto"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultShareExpiry = 7 * 24 * time.Hour
+	maxShareExpiry     = 365 * 24 * time.Hour
+	maxPasswordLength  = 72 // bcrypt ignores anything longer
+)
+
+var (
+	errImageNotFound = errors.New("image not found")
+	errForbidden     = errors.New("forbidden")
+	errLinkNotFound  = errors.New("share link not found")
+	errLinkExpired   = errors.New("share link expired")
+	errWrongPassword = errors.New("wrong password")
+)
+
+// validationError wraps invalid user input so controllers answer 400
+type validationError struct {
+	err error
+}
+
+func (e *validationError) Error() string {
+	return e.err.Error()
+}
+
+func createShare(ctx context.Context, imageID string, requester string, req dto.ShareCreateReqDto) (*dto.ShareResponse, error) {
+	detail, err := postgressqueries.GetImageDetail(ctx, imageID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && detail.DeletedAt != nil) {
+		return nil, errImageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if requester == "" || requester != detail.UploadedBy {
+		return nil, errForbidden
+	}
+
+	expiry := defaultShareExpiry
+	if req.ExpiresIn != 0 {
+		expiry = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if expiry <= 0 || expiry > maxShareExpiry {
+		return nil, &validationError{fmt.Errorf("expires_in must be between 1 and %d seconds", int64(maxShareExpiry.Seconds()))}
+	}
+	if req.MaxViews < 0 {
+		return nil, &validationError{errors.New("max_views must not be negative")}
+	}
+	if len(req.Password) > maxPasswordLength {
+		return nil, &validationError{fmt.Errorf("password must be at most %d bytes", maxPasswordLength)}
+	}
+
+	id, err := newLinkID()
+	if err != nil {
+		return nil, err
+	}
+
+	link := dto.ShareLink{
+		ID:        id,
+		ImageID:   imageID,
+		CreatedBy: requester,
+		ExpiresAt: time.Now().Add(expiry),
+		MaxViews:  req.MaxViews,
+	}
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		link.PasswordHash = string(hash)
+	}
+
+	if err := postgressqueries.CreateShareLink(ctx, link); err != nil {
+		return nil, err
+	}
+
+	token := signToken(id)
+	return &dto.ShareResponse{
+		Token:             token,
+		URL:               "/s/" + token,
+		ExpiresAt:         link.ExpiresAt,
+		MaxViews:          link.MaxViews,
+		PasswordProtected: link.PasswordHash != "",
+	}, nil
+}
+
+// revokeShare stops a link from resolving, only its creator may revoke it
+func revokeShare(ctx context.Context, token string, requester string) error {
+	id, ok := verifyToken(token)
+	if !ok {
+		return errLinkNotFound
+	}
+
+	// Other users' links are reported missing rather than forbidden, so
+	// tokens cannot be probed
+	err := postgressqueries.RevokeShareLink(ctx, id, requester)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errLinkNotFound
+	}
+	return err
+}
+
+// resolveShare checks a token and its password, counts the view and returns
+// the shared image. Shared images still have to pass moderation.
+func resolveShare(ctx context.Context, token string, password string) (*imagedto.ImageDetail, error) {
+	id, ok := verifyToken(token)
+	if !ok {
+		return nil, errLinkNotFound
+	}
+
+	link, err := postgressqueries.GetShareLink(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if link.PasswordHash != "" {
+		if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+			return nil, errWrongPassword
+		}
+	}
+
+	err = postgressqueries.ConsumeShareView(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errLinkExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	detail, err := postgressqueries.GetImageDetail(ctx, link.ImageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if detail.ModerationStatus != "approved" || detail.DeletedAt != nil {
+		return nil, errLinkNotFound
+	}
+
+	detail.ModerationStatus = ""
+	return detail, nil
+}
@@ -0,0 +1,67 @@
+package share
+
+import (
+	"MAIN_SERVER/config"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+var (
+	secret     []byte
+	secretOnce sync.Once
+)
+
+// signingSecret returns auth.share_token_secret, or a random per-process secret
+// when it is not configured (links then stop working after a restart)
+func signingSecret() []byte {
+	secretOnce.Do(func() {
+		if value := config.Get().Auth.ShareTokenSecret; value != "" {
+			secret = []byte(value)
+			return
+		}
+
+		slog.Warn("auth.share_token_secret is not set, share links will not survive a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	})
+	return secret
+}
+
+// newLinkID returns a random, URL-safe share link id
+func newLinkID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func signature(id string) string {
+	mac := hmac.New(sha256.New, signingSecret())
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signToken builds the public token "<id>.<hmac>" of a link id
+func signToken(id string) string {
+	return id + "." + signature(id)
+}
+
+// verifyToken returns the link id of a token with a valid signature
+func verifyToken(token string) (string, bool) {
+	id, sig, found := strings.Cut(token, ".")
+	if !found || id == "" {
+		return "", false
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(id))) {
+		return "", false
+	}
+	return id, true
+}
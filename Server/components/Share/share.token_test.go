@@ -0,0 +1,76 @@
+package share
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Sign with a fixed secret instead of reading the config
+	secretOnce.Do(func() {
+		secret = []byte("test secret")
+	})
+	os.Exit(m.Run())
+}
+
+func TestSignedTokenVerifies(t *testing.T) {
+	id, err := newLinkID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := verifyToken(signToken(id))
+	if !ok || got != id {
+		t.Errorf("verifyToken(signToken(%q)) = %q, %v", id, got, ok)
+	}
+}
+
+func TestVerifyTokenRejectsForgeries(t *testing.T) {
+	id, _ := newLinkID()
+	other, _ := newLinkID()
+	token := signToken(id)
+	_, sig, _ := strings.Cut(token, ".")
+
+	forged := []string{
+		"",
+		id,
+		id + ".",
+		"." + sig,
+		other + "." + sig,
+		id + "." + strings.ToUpper(sig),
+		token[:len(token)-1],
+	}
+	for _, token := range forged {
+		if got, ok := verifyToken(token); ok {
+			t.Errorf("verifyToken(%q) = %q, accepted", token, got)
+		}
+	}
+}
+
+func TestNewLinkIDIsUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 100 {
+		id, err := newLinkID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[id] || strings.Contains(id, ".") {
+			t.Fatalf("newLinkID() = %q, duplicate or contains the separator", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestRevokeShareRejectsBadTokens(t *testing.T) {
+	id, _ := newLinkID()
+
+	// Bad tokens fail before the database is queried
+	for _, token := range []string{"", id, id + ".forged", "." + signature(id)} {
+		if err := revokeShare(context.Background(), token, "owner"); !errors.Is(err, errLinkNotFound) {
+			t.Errorf("revokeShare(%q) error = %v, want errLinkNotFound", token, err)
+		}
+	}
+}
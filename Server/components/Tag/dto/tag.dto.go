@@ -0,0 +1,11 @@
+package dto
+
+type TagAutocompleteReqDto struct {
+	Prefix string `query:"prefix"`
+	Limit  int    `query:"limit"`
+}
+
+type TagResponse struct {
+	Name       string `json:"name"`
+	ImageCount int    `json:"image_count"` // Visible images carrying the tag
+}
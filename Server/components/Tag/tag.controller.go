@@ -0,0 +1,29 @@
+package tag
+
+import (
+	"MAIN_SERVER/components/Tag/dto"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func autocompleteTagsController(c *fiber.Ctx) error {
+
+	var tagDto dto.TagAutocompleteReqDto
+
+	// Parse the query string
+	if err := c.QueryParser(&tagDto); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid query parameters")
+	}
+
+	tags, err := autocompleteTags(c.UserContext(), tagDto.Prefix, tagDto.Limit)
+	if err != nil {
+		return fmt.Errorf("fetching tags: %w", err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+
+	return c.JSON(fiber.Map{
+		"tags": tags,
+	})
+}
@@ -0,0 +1,65 @@
+package tag
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	MaxTagLength    = 32
+	MaxTagsPerImage = 10
+)
+
+// Normalize lowercases a tag and slugifies it: runs of characters other than
+// ASCII letters and digits become a single '-', leading and trailing dashes
+// are dropped. The result may be empty.
+func Normalize(raw string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(raw) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if len(slug) > MaxTagLength {
+		slug = strings.TrimSuffix(slug[:MaxTagLength], "-")
+	}
+	return slug
+}
+
+// NormalizeList normalizes and de-duplicates tags, dropping empty ones
+func NormalizeList(raw []string) ([]string, error) {
+	seen := make(map[string]bool)
+	tags := []string{}
+
+	for _, value := range raw {
+		slug := Normalize(value)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		tags = append(tags, slug)
+	}
+
+	if len(tags) > MaxTagsPerImage {
+		return nil, fmt.Errorf("at most %d tags are allowed per image", MaxTagsPerImage)
+	}
+	return tags, nil
+}
+
+// SplitList splits a comma-separated form value into raw tags
+func SplitList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
@@ -0,0 +1,15 @@
+package tag
+
+import (
+	"MAIN_SERVER/auth"
+	apikeydto "MAIN_SERVER/components/ApiKey/dto"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func Routes(app *fiber.App) {
+	grp := app.Group("/tags")
+
+	grp.Get("/", auth.RequireScope(apikeydto.ScopeRead), autocompleteTagsController)
+
+}
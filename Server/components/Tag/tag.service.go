@@ -0,0 +1,23 @@
+package tag
+
+import (
+	"MAIN_SERVER/components/Tag/dto"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"context"
+)
+
+const (
+	defaultAutocompleteLimit = 10
+	maxAutocompleteLimit     = 50
+)
+
+func autocompleteTags(ctx context.Context, prefix string, limit int) ([]dto.TagResponse, error) {
+	if limit <= 0 {
+		limit = defaultAutocompleteLimit
+	}
+	if limit > maxAutocompleteLimit {
+		limit = maxAutocompleteLimit
+	}
+
+	return postgressqueries.AutocompleteTags(ctx, Normalize(prefix), limit)
+}
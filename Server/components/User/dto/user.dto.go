@@ -0,0 +1,18 @@
+package dto
+
+import "time"
+
+type UserProfileResponse struct {
+	UserName           string    `json:"username"`
+	UploadCount        int       `json:"upload_count"` // Includes hidden uploads for the owner
+	TotalLikesReceived int64     `json:"total_likes_received"`
+	JoinedAt           time.Time `json:"joined_at"`
+	AvatarImageID      string    `json:"avatar_image_id"`
+	AvatarThumbnail    string    `json:"avatar_thumbnail"`
+}
+
+// UserProfileUpdateReqDto is the body of PATCH /users/:name, an empty
+// avatar_image_id clears the avatar
+type UserProfileUpdateReqDto struct {
+	AvatarImageID *string `json:"avatar_image_id"`
+}
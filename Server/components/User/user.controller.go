@@ -0,0 +1,108 @@
+package user
+
+import (
+	"MAIN_SERVER/auth"
+	imagedto "MAIN_SERVER/components/Image/dto"
+	"MAIN_SERVER/components/User/dto"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// userError maps service errors to HTTP errors
+func userError(err error) error {
+	var invalid *validationError
+
+	switch {
+	case errors.As(err, &invalid):
+		return fiber.NewError(fiber.StatusBadRequest, invalid.Error())
+	case errors.Is(err, postgressqueries.ErrInvalidCursor):
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid cursor")
+	case errors.Is(err, errUserNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	case errors.Is(err, errUnauthenticated):
+		return fiber.NewError(fiber.StatusUnauthorized, "You must be signed in")
+	case errors.Is(err, errForbidden):
+		return fiber.NewError(fiber.StatusForbidden, "You can only change your own profile")
+	}
+
+	return fmt.Errorf("handling user: %w", err)
+}
+
+// setCacheControl keeps the owner's view, which includes hidden uploads, out
+// of shared caches. The owner is named by X-Username or an API key.
+func setCacheControl(c *fiber.Ctx, isOwner bool) {
+	c.Vary("X-Username", fiber.HeaderAuthorization)
+	if isOwner {
+		c.Set(fiber.HeaderCacheControl, "private, no-cache")
+	} else {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+	}
+}
+
+func getUserProfileController(c *fiber.Ctx) error {
+
+	userName := c.Params("name")
+	requester := auth.UserName(c)
+
+	profile, err := getUserProfile(c.UserContext(), userName, requester)
+	if err != nil {
+		return userError(err)
+	}
+
+	setCacheControl(c, requester == userName)
+	return c.JSON(profile)
+}
+
+func listUserImagesController(c *fiber.Ctx) error {
+
+	var query imagedto.ImageListQueryDto
+
+	// Parse the query string
+	if err := c.QueryParser(&query); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid query parameters")
+	}
+
+	userName := c.Params("name")
+	requester := auth.UserName(c)
+
+	files, nextCursor, err := listUserImages(c.UserContext(), userName, requester, query)
+	if err != nil {
+		return userError(err)
+	}
+
+	setCacheControl(c, requester == userName)
+	return c.JSON(fiber.Map{
+		"files":       files,
+		"next_cursor": nextCursor,
+	})
+}
+
+func getOwnUsageController(c *fiber.Ctx) error {
+
+	usage, err := getOwnUsage(c.UserContext(), auth.UserName(c))
+	if err != nil {
+		return userError(err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.JSON(usage)
+}
+
+func updateUserProfileController(c *fiber.Ctx) error {
+
+	var patch dto.UserProfileUpdateReqDto
+
+	if err := c.BodyParser(&patch); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	profile, err := updateUserProfile(c.UserContext(), c.Params("name"), auth.UserName(c), patch)
+	if err != nil {
+		return userError(err)
+	}
+
+	return c.JSON(profile)
+}
@@ -0,0 +1,26 @@
+package user
+
+import (
+	"MAIN_SERVER/auth"
+	apikeydto "MAIN_SERVER/components/ApiKey/dto"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/etag"
+)
+
+func Routes(app *fiber.App) {
+	grp := app.Group("/users")
+
+	// API keys may only call the routes their scopes cover
+	canRead := auth.RequireScope(apikeydto.ScopeRead)
+	canWrite := auth.RequireScope(apikeydto.ScopeWrite)
+
+	cacheable := etag.New()
+
+	grp.Get("/:name", canRead, cacheable, getUserProfileController)
+	grp.Patch("/:name", canWrite, updateUserProfileController)
+	grp.Get("/:name/images", canRead, cacheable, listUserImagesController)
+
+	app.Get("/me/usage", canRead, getOwnUsageController)
+
+}
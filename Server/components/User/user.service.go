@@ -0,0 +1,76 @@
+package user
+
+import (
+	imagedto "MAIN_SERVER/components/Image/dto"
+	"MAIN_SERVER/components/User/dto"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"MAIN_SERVER/quota"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var (
+	errUserNotFound    = errors.New("user not found")
+	errUnauthenticated = errors.New("unauthenticated")
+	errForbidden       = errors.New("forbidden")
+)
+
+// validationError wraps invalid user input so controllers answer 400
+type validationError struct {
+	err error
+}
+
+func (e *validationError) Error() string {
+	return e.err.Error()
+}
+
+func getUserProfile(ctx context.Context, userName string, requester string) (*dto.UserProfileResponse, error) {
+	profile, err := postgressqueries.GetUserProfile(ctx, userName, requester == userName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errUserNotFound
+	}
+	return profile, err
+}
+
+// listUserImages lists a user's gallery, the owner also sees pending,
+// rejected, unlisted and private images along with their moderation status
+func listUserImages(ctx context.Context, userName string, requester string, query imagedto.ImageListQueryDto) ([]imagedto.FileResponse, string, error) {
+	query.User = userName
+	if requester == userName {
+		return postgressqueries.ListOwnImagesByCursor(ctx, query)
+	}
+	return postgressqueries.ListImagesByCursor(ctx, query)
+}
+
+func getOwnUsage(ctx context.Context, requester string) (*quota.Usage, error) {
+	if requester == "" {
+		return nil, errUnauthenticated
+	}
+	return quota.GetUsage(ctx, requester)
+}
+
+func updateUserProfile(ctx context.Context, userName string, requester string, patch dto.UserProfileUpdateReqDto) (*dto.UserProfileResponse, error) {
+	if requester == "" || requester != userName {
+		return nil, errForbidden
+	}
+
+	if patch.AvatarImageID != nil {
+		// Avatars are shown publicly, only approved own images qualify
+		if imageID := *patch.AvatarImageID; imageID != "" {
+			detail, err := postgressqueries.GetImageDetail(ctx, imageID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+			if err != nil || !detail.CanFeature(userName) {
+				return nil, &validationError{errors.New("avatar_image_id must be one of your approved, non-private images")}
+			}
+		}
+
+		if err := postgressqueries.SetUserAvatar(ctx, userName, *patch.AvatarImageID); err != nil {
+			return nil, err
+		}
+	}
+
+	return getUserProfile(ctx, userName, requester)
+}
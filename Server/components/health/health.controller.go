@@ -0,0 +1,28 @@
+package health
+
+import "github.com/gofiber/fiber/v2"
+
+// livenessController answers as long as the process serves requests, it
+// checks no dependencies so a database outage does not get the server killed
+func livenessController(c *fiber.Ctx) error {
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	return c.JSON(fiber.Map{
+		"status": "ok",
+	})
+}
+
+// readinessController answers 503 while a dependency is failing or the
+// server is draining, so load balancers stop routing to it
+func readinessController(c *fiber.Ctx) error {
+
+	report := checkReadiness(c.UserContext())
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if !report.Ready {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+
+	return c.JSON(report)
+}
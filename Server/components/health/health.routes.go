@@ -0,0 +1,10 @@
+package health
+
+import "github.com/gofiber/fiber/v2"
+
+func Routes(app *fiber.App) {
+
+	app.Get("/healthz", livenessController)
+	app.Get("/readyz", readinessController)
+
+}
@@ -0,0 +1,146 @@
+package health
+
+import (
+	"MAIN_SERVER/config"
+	"MAIN_SERVER/gcs"
+	"MAIN_SERVER/logging"
+	postgresql "MAIN_SERVER/postgress"
+	worker "MAIN_SERVER/workerpool"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// draining is set once shutdown starts
+var draining atomic.Bool
+
+// StartDraining makes /readyz fail from now on
+func StartDraining() {
+	draining.Store(true)
+}
+
+// Check is the outcome of one readiness check. /readyz is public, so only
+// the status is reported and the error is logged.
+type Check struct {
+	OK      bool          `json:"ok"`
+	Err     error         `json:"-"`
+	Latency time.Duration `json:"-"`
+}
+
+// Report is the body of /readyz
+type Report struct {
+	Ready    bool             `json:"ready"`
+	Draining bool             `json:"draining"`
+	Checks   map[string]Check `json:"checks"`
+}
+
+// checks are run on every readiness probe, each under the check timeout
+var checks = map[string]func(ctx context.Context) error{
+	"config":   checkConfig,
+	"postgres": checkPostgres,
+	"storage":  checkStorage,
+	"workers":  checkWorkers,
+}
+
+// checkReadiness runs every check concurrently
+func checkReadiness(ctx context.Context) Report {
+	report := Report{
+		Draining: draining.Load(),
+		Checks:   make(map[string]Check, len(checks)),
+	}
+
+	// Without a config there is no timeout either, fall back to the default
+	timeout := 2 * time.Second
+	if config.Loaded() {
+		timeout = config.Get().Health.CheckTimeout.Std()
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := run(ctx, timeout, check)
+			if !result.OK {
+				logging.FromContext(ctx).Warn("readiness check failed",
+					"check", name,
+					"err", result.Err,
+					"latency_ms", float64(result.Latency.Microseconds())/1000,
+				)
+			}
+
+			lock.Lock()
+			report.Checks[name] = result
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	report.Ready = !report.Draining
+	for _, check := range report.Checks {
+		report.Ready = report.Ready && check.OK
+	}
+	return report
+}
+
+// run times one check, a check that outlives its timeout fails even if the
+// dependency ignores the context
+func run(ctx context.Context, timeout time.Duration, check func(ctx context.Context) error) Check {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	return Check{OK: err == nil, Err: err, Latency: time.Since(start)}
+}
+
+func checkConfig(ctx context.Context) error {
+	if !config.Loaded() {
+		return errors.New("not loaded")
+	}
+	return nil
+}
+
+func checkPostgres(ctx context.Context) error {
+	if postgresql.PostgresConnection == nil {
+		return errors.New("not connected")
+	}
+	return postgresql.PostgresConnection.PingContext(ctx)
+}
+
+// checkStorage asks the storage backend who we are, the cheapest
+// authenticated call
+func checkStorage(ctx context.Context) error {
+	if gcs.DriveService == nil {
+		return errors.New("not connected")
+	}
+	_, err := gcs.DriveService.About.Get().Fields("user").Context(ctx).Do()
+	return err
+}
+
+// checkWorkers fails while the upload queue is full, new uploads would block
+func checkWorkers(ctx context.Context) error {
+	queue := worker.TaskChan
+	if queue == nil {
+		return errors.New("not started")
+	}
+	if len(queue) >= cap(queue) {
+		return fmt.Errorf("upload queue is full (%d tasks)", cap(queue))
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestReadiness(t *testing.T) {
+	secret := "dial tcp 10.0.3.7:5432: connection refused"
+	pass := func(context.Context) error { return nil }
+	fail := func(context.Context) error { return errors.New(secret) }
+
+	tests := []struct {
+		name       string
+		checks     map[string]func(context.Context) error
+		draining   bool
+		wantStatus int
+		wantBody   string
+		wantLogged bool
+	}{
+		{
+			name:       "ready",
+			checks:     map[string]func(context.Context) error{"postgres": pass, "storage": pass},
+			wantStatus: fiber.StatusOK,
+			wantBody:   `{"ready":true,"draining":false,"checks":{"postgres":{"ok":true},"storage":{"ok":true}}}`,
+		},
+		{
+			name:       "failing check",
+			checks:     map[string]func(context.Context) error{"postgres": fail, "storage": pass},
+			wantStatus: fiber.StatusServiceUnavailable,
+			wantBody:   `{"ready":false,"draining":false,"checks":{"postgres":{"ok":false},"storage":{"ok":true}}}`,
+			wantLogged: true,
+		},
+		{
+			name:       "draining",
+			checks:     map[string]func(context.Context) error{"postgres": pass},
+			draining:   true,
+			wantStatus: fiber.StatusServiceUnavailable,
+			wantBody:   `{"ready":false,"draining":true,"checks":{"postgres":{"ok":true}}}`,
+		},
+	}
+
+	defer func(original map[string]func(context.Context) error) { checks = original }(checks)
+	defer slog.SetDefault(slog.Default())
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+			checks = test.checks
+			draining.Store(test.draining)
+			defer draining.Store(false)
+
+			app := fiber.New()
+			Routes(app)
+			response, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/readyz", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+			body, _ := io.ReadAll(response.Body)
+
+			if response.StatusCode != test.wantStatus {
+				t.Errorf("status = %d, want %d", response.StatusCode, test.wantStatus)
+			}
+			var got, want any
+			json.Unmarshal(body, &got)
+			json.Unmarshal([]byte(test.wantBody), &want)
+			if !jsonEqual(got, want) {
+				t.Errorf("body = %s, want %s", body, test.wantBody)
+			}
+
+			// The error goes to the log, never to the client
+			if strings.Contains(string(body), secret) {
+				t.Errorf("body leaks the error: %s", body)
+			}
+			if logged := strings.Contains(logs.String(), secret); logged != test.wantLogged {
+				t.Errorf("error logged = %v, want %v", logged, test.wantLogged)
+			}
+		})
+	}
+}
+
+func jsonEqual(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
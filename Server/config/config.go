@@ -0,0 +1,303 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	shared "SHARED_CONFIG"
+	"SHARED_CONFIG/forwarding"
+)
+
+// Config is the typed configuration of the server
+type Config struct {
+	HTTP       shared.HTTP    `json:"http" yaml:"http"`
+	Log        shared.Log     `json:"log" yaml:"log"`
+	Tracing    shared.Tracing `json:"tracing" yaml:"tracing"`
+	Metrics    shared.Metrics `json:"metrics" yaml:"metrics"`
+	Database   Database       `json:"database" yaml:"database"`
+	Storage    Storage        `json:"storage" yaml:"storage"`
+	Workers    Workers        `json:"workers" yaml:"workers"`
+	Uploads    Uploads        `json:"uploads" yaml:"uploads"`
+	Limits     Limits         `json:"limits" yaml:"limits"`
+	RateLimits RateLimits     `json:"rate_limits" yaml:"rate_limits"`
+	Auth       Auth           `json:"auth" yaml:"auth"`
+	Moderation Moderation     `json:"moderation" yaml:"moderation"`
+	Health     Health         `json:"health" yaml:"health"`
+}
+
+type Database struct {
+	DSN          string `json:"dsn" yaml:"dsn" env:"DATABASE_URL"`
+	MaxOpenConns int    `json:"max_open_conns" yaml:"max_open_conns" env:"DATABASE_MAX_OPEN_CONNS" default:"20"`
+	MaxIdleConns int    `json:"max_idle_conns" yaml:"max_idle_conns" env:"DATABASE_MAX_IDLE_CONNS" default:"5"`
+}
+
+// StorageDrive stores images in a Google Drive folder
+const StorageDrive = "drive"
+
+type Storage struct {
+	Backend         string `json:"backend" yaml:"backend" env:"STORAGE_BACKEND" default:"drive"`
+	FolderID        string `json:"folder_id" yaml:"folder_id" env:"FOLDER_ID"`
+	CredentialsFile string `json:"credentials_file" yaml:"credentials_file" env:"GOOGLE_CREDENTIALS_FILE" default:"creds.json"`
+}
+
+type Workers struct {
+	Uploads     int `json:"uploads" yaml:"uploads" env:"UPLOAD_WORKERS" default:"25"`
+	UploadQueue int `json:"upload_queue" yaml:"upload_queue" env:"UPLOAD_QUEUE_SIZE" default:"100"`
+	Thumbnails  int `json:"thumbnails" yaml:"thumbnails" env:"THUMBNAIL_WORKERS" default:"5"`
+}
+
+// Uploads is the upload policy
+type Uploads struct {
+	MaxRequestBytes int      `json:"max_request_bytes" yaml:"max_request_bytes" env:"MAX_UPLOAD_BYTES" default:"104857600"`
+	MaxFileBytes    int64    `json:"max_file_bytes" yaml:"max_file_bytes" env:"MAX_FILE_BYTES" default:"20971520"`
+	MaxFiles        int      `json:"max_files" yaml:"max_files" env:"MAX_FILES_PER_UPLOAD" default:"20"`
+	AllowedTypes    []string `json:"allowed_types" yaml:"allowed_types" env:"ALLOWED_UPLOAD_TYPES" default:"image/jpeg,image/png,image/gif,image/webp"`
+}
+
+// AllowsType reports whether files of contentType may be uploaded
+func (u Uploads) AllowsType(contentType string) bool {
+	return slices.Contains(u.AllowedTypes, contentType)
+}
+
+// TierQuota caps the storage of the users of one tier
+type TierQuota struct {
+	MaxBytes int64 `json:"max_bytes" yaml:"max_bytes" env:"MAX_BYTES"`
+	MaxFiles int   `json:"max_files" yaml:"max_files" env:"MAX_FILES"`
+}
+
+type Limits struct {
+	DeleteRetentionHours int       `json:"delete_retention_hours" yaml:"delete_retention_hours" env:"DELETE_RETENTION_HOURS" default:"168"`
+	FreeQuota            TierQuota `json:"free_quota" yaml:"free_quota" env:"QUOTA_FREE_"`
+	ProQuota             TierQuota `json:"pro_quota" yaml:"pro_quota" env:"QUOTA_PRO_"`
+}
+
+// RateLimit allows Limit requests per Period, in bursts of up to Limit
+type RateLimit struct {
+	Limit  int             `json:"limit" yaml:"limit" env:"LIMIT"`
+	Period shared.Duration `json:"period" yaml:"period" env:"PERIOD"`
+}
+
+type RateLimits struct {
+	Upload  RateLimit `json:"upload" yaml:"upload" env:"RATE_UPLOAD_"`
+	Like    RateLimit `json:"like" yaml:"like" env:"RATE_LIKE_"`
+	Listing RateLimit `json:"listing" yaml:"listing" env:"RATE_LISTING_"`
+	Search  RateLimit `json:"search" yaml:"search" env:"RATE_SEARCH_"`
+	Share   RateLimit `json:"share" yaml:"share" env:"RATE_SHARE_"` // Share link visits, per IP and per link
+	Comment RateLimit `json:"comment" yaml:"comment" env:"RATE_COMMENT_"`
+}
+
+// ByName returns the rate of a named rate limit policy
+func (r RateLimits) ByName(name string) (RateLimit, bool) {
+	switch name {
+	case "upload":
+		return r.Upload, true
+	case "like":
+		return r.Like, true
+	case "listing":
+		return r.Listing, true
+	case "search":
+		return r.Search, true
+	case "share":
+		return r.Share, true
+	case "comment":
+		return r.Comment, true
+	}
+	return RateLimit{}, false
+}
+
+type Auth struct {
+	AdminUsers       []string `json:"admin_users" yaml:"admin_users" env:"ADMIN_USERS"`
+	ShareTokenSecret string   `json:"share_token_secret" yaml:"share_token_secret" env:"SHARE_TOKEN_SECRET"`
+	TrustedProxies   []string `json:"trusted_proxies" yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+type Moderation struct {
+	BlockedWords []string `json:"blocked_words" yaml:"blocked_words" env:"BLOCKED_WORDS"`
+}
+
+// Validate reports every bad setting
+func (c *Config) Validate(p *shared.Problems) {
+	c.HTTP.Validate("http", p)
+	c.Log.Validate("log", p)
+	c.Tracing.Validate("tracing", p)
+	c.Metrics.Validate("metrics", p)
+
+	p.Require("database.dsn", c.Database.DSN)
+	p.Positive("database.max_open_conns", int64(c.Database.MaxOpenConns))
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		p.Add("database.max_idle_conns", "must be between 0 and max_open_conns, got %d", c.Database.MaxIdleConns)
+	}
+
+	switch c.Storage.Backend {
+	case StorageDrive:
+		p.Require("storage.folder_id", c.Storage.FolderID)
+		p.Require("storage.credentials_file", c.Storage.CredentialsFile)
+	default:
+		p.Add("storage.backend", "must be %q, got %q", StorageDrive, c.Storage.Backend)
+	}
+
+	p.Positive("workers.uploads", int64(c.Workers.Uploads))
+	p.Positive("workers.upload_queue", int64(c.Workers.UploadQueue))
+	p.Positive("workers.thumbnails", int64(c.Workers.Thumbnails))
+
+	p.Positive("uploads.max_request_bytes", int64(c.Uploads.MaxRequestBytes))
+	p.Positive("uploads.max_file_bytes", c.Uploads.MaxFileBytes)
+	p.Positive("uploads.max_files", int64(c.Uploads.MaxFiles))
+	if len(c.Uploads.AllowedTypes) == 0 {
+		p.Add("uploads.allowed_types", "must list at least one content type")
+	}
+
+	if c.Limits.DeleteRetentionHours < 0 {
+		p.Add("limits.delete_retention_hours", "must not be negative, got %d", c.Limits.DeleteRetentionHours)
+	}
+	for name, quota := range map[string]TierQuota{"free_quota": c.Limits.FreeQuota, "pro_quota": c.Limits.ProQuota} {
+		p.Positive("limits."+name+".max_bytes", quota.MaxBytes)
+		p.Positive("limits."+name+".max_files", int64(quota.MaxFiles))
+	}
+
+	for _, name := range []string{"upload", "like", "listing", "search", "share", "comment"} {
+		rate, _ := c.RateLimits.ByName(name)
+		p.Positive("rate_limits."+name+".limit", int64(rate.Limit))
+		if rate.Period.Std() < time.Second {
+			p.Add("rate_limits."+name+".period", "must be at least 1s, got %s", rate.Period.Std())
+		}
+	}
+
+	if _, err := forwarding.ParseProxies(c.Auth.TrustedProxies); err != nil {
+		p.Add("auth.trusted_proxies", "%v", err)
+	}
+	if secret := c.Auth.ShareTokenSecret; secret != "" && len(secret) < 16 {
+		p.Add("auth.share_token_secret", "must be at least 16 characters")
+	}
+
+	if c.Health.CheckTimeout.Std() <= 0 {
+		p.Add("health.check_timeout", "must be positive, got %s", c.Health.CheckTimeout.Std())
+	}
+	if c.Health.DrainDelay.Std() < 0 {
+		p.Add("health.drain_delay", "must not be negative, got %s", c.Health.DrainDelay.Std())
+	}
+}
+
+// Health tunes the readiness checks and graceful shutdown
+type Health struct {
+	CheckTimeout shared.Duration `json:"check_timeout" yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+	// DrainDelay is how long /readyz fails before the server stops
+	// accepting connections, so load balancers stop sending requests first
+	DrainDelay shared.Duration `json:"drain_delay" yaml:"drain_delay" env:"SHUTDOWN_DRAIN_DELAY" default:"5s"`
+}
+
+// IsAdmin reports whether userName is listed in auth.admin_users
+func (c *Config) IsAdmin(userName string) bool {
+	for _, admin := range c.Auth.AdminUsers {
+		if admin == userName {
+			return true
+		}
+	}
+	return false
+}
+
+var current atomic.Pointer[Config]
+
+// Load reads the config from path and the environment and makes it current,
+// returning every invalid setting at once
+func Load(path string) (*Config, error) {
+	cfg, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current.Store(cfg)
+	return cfg, nil
+}
+
+// Reload loads path again and swaps the new config in when it is valid.
+// Settings only read at startup keep their current value until a restart.
+func Reload(path string) (*Config, error) {
+	next, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	prev := Get()
+	restartOnly := []struct {
+		field   string
+		changed bool
+		keep    func()
+	}{
+		{"http.listen_addr", next.HTTP.ListenAddr != prev.HTTP.ListenAddr, func() { next.HTTP.ListenAddr = prev.HTTP.ListenAddr }},
+		{"http.cors_methods", !slices.Equal(next.HTTP.CORSMethods, prev.HTTP.CORSMethods), func() { next.HTTP.CORSMethods = prev.HTTP.CORSMethods }},
+		{"tracing", next.Tracing != prev.Tracing, func() { next.Tracing = prev.Tracing }},
+		{"metrics.listen_addr", next.Metrics != prev.Metrics, func() { next.Metrics = prev.Metrics }},
+		{"database", next.Database != prev.Database, func() { next.Database = prev.Database }},
+		{"storage", next.Storage != prev.Storage, func() { next.Storage = prev.Storage }},
+		{"uploads.max_request_bytes", next.Uploads.MaxRequestBytes != prev.Uploads.MaxRequestBytes, func() { next.Uploads.MaxRequestBytes = prev.Uploads.MaxRequestBytes }},
+		{"workers.upload_queue", next.Workers.UploadQueue != prev.Workers.UploadQueue, func() { next.Workers.UploadQueue = prev.Workers.UploadQueue }},
+		{"workers.thumbnails", next.Workers.Thumbnails != prev.Workers.Thumbnails, func() { next.Workers.Thumbnails = prev.Workers.Thumbnails }},
+		{"auth.share_token_secret", next.Auth.ShareTokenSecret != prev.Auth.ShareTokenSecret, func() { next.Auth.ShareTokenSecret = prev.Auth.ShareTokenSecret }},
+		{"auth.trusted_proxies", !slices.Equal(next.Auth.TrustedProxies, prev.Auth.TrustedProxies), func() { next.Auth.TrustedProxies = prev.Auth.TrustedProxies }},
+		{"moderation.blocked_words", !slices.Equal(next.Moderation.BlockedWords, prev.Moderation.BlockedWords), func() { next.Moderation.BlockedWords = prev.Moderation.BlockedWords }},
+	}
+	for _, setting := range restartOnly {
+		if setting.changed {
+			slog.Warn("config setting changed, restart to apply it", "field", setting.field)
+			setting.keep()
+		}
+	}
+
+	current.Store(next)
+	return next, nil
+}
+
+// Watch reloads the config on SIGHUP and when its file changes, calling
+// onReload with every config swapped in
+func Watch(path string, onReload func(*Config)) *shared.Watcher {
+	return shared.Watch(path, 5*time.Second, func() error {
+		cfg, err := Reload(path)
+		if err != nil {
+			return err
+		}
+		onReload(cfg)
+		return nil
+	})
+}
+
+// load reads a fresh config without making it current
+func load(path string) (*Config, error) {
+	cfg := &Config{
+		HTTP:    shared.HTTP{ListenAddr: "0.0.0.0:3001"},
+		Metrics: shared.Metrics{ListenAddr: "127.0.0.1:3201"},
+		Limits: Limits{
+			FreeQuota: TierQuota{MaxBytes: 1 << 30, MaxFiles: 1000},
+			ProQuota:  TierQuota{MaxBytes: 50 << 30, MaxFiles: 50000},
+		},
+		RateLimits: RateLimits{
+			Upload:  RateLimit{Limit: 30, Period: shared.Duration(time.Hour)},
+			Like:    RateLimit{Limit: 60, Period: shared.Duration(time.Minute)},
+			Listing: RateLimit{Limit: 120, Period: shared.Duration(time.Minute)},
+			Search:  RateLimit{Limit: 60, Period: shared.Duration(time.Minute)},
+			Share:   RateLimit{Limit: 20, Period: shared.Duration(time.Minute)},
+			Comment: RateLimit{Limit: 5, Period: shared.Duration(time.Minute)},
+		},
+	}
+	if err := shared.Load(path, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Loaded reports whether Load has succeeded
+func Loaded() bool {
+	return current.Load() != nil
+}
+
+// Get returns the loaded config, main must call Load first
+func Get() *Config {
+	cfg := current.Load()
+	if cfg == nil {
+		panic(fmt.Errorf("config used before it was loaded"))
+	}
+	return cfg
+}
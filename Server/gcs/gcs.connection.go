@@ -1,8 +1,9 @@
 package gcs
 
 import (
+	"MAIN_SERVER/config"
 	"context"
-	"fmt"
+	"log/slog"
 	"os"
 
 	"golang.org/x/oauth2/google"
@@ -17,22 +18,22 @@ func GetDriveService() {
 	ctx := context.Background()
 
 	// Load credentials file
-	b, err := os.ReadFile("creds.json")
+	b, err := os.ReadFile(config.Get().Storage.CredentialsFile)
 	if err != nil {
-		fmt.Printf("unable to read client secret file: %v", err)
+		slog.Error("unable to read client secret file", "err", err)
 	}
 
 	// Use the credentials to create a Google Drive client
 	config, err := google.JWTConfigFromJSON(b, drive.DriveFileScope)
 	if err != nil {
-		fmt.Printf("unable to parse client secret file to config: %v", err)
+		slog.Error("unable to parse client secret file to config", "err", err)
 	}
 
 	client := config.Client(ctx)
 	DriveService, err = drive.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
-		fmt.Printf("unable to retrieve Drive client: %v", err)
+		slog.Error("unable to retrieve Drive client", "err", err)
 	}
 
-	fmt.Println("Connected to Google Drive API")
+	slog.Info("connected to Google Drive API")
 }
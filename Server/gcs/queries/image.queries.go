@@ -1,29 +1,56 @@
 package queries
 
 import (
+	"MAIN_SERVER/components/Image/dto"
 	"MAIN_SERVER/gcs"
+	"MAIN_SERVER/logging"
 	postgressqueries "MAIN_SERVER/postgress/queries"
+	"MAIN_SERVER/quota"
+	"context"
 	"fmt"
-	"log"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
 	"mime/multipart"
-	"sync"
+	"net/http"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 )
 
-func UploadImageToDrive(file *multipart.FileHeader, folderID string, wg *sync.WaitGroup, errChan chan<- error, userName string) {
+var tracer = otel.Tracer("MAIN_SERVER/gcs/queries")
 
-	defer wg.Done()
+// UploadImageToDrive stores one uploaded file and records it in the database,
+// logging through the logger carried by ctx
+func UploadImageToDrive(ctx context.Context, file *multipart.FileHeader, folderID string, userName string, metadata dto.ImageMetadata) error {
+
+	// The quota was reserved when the upload was accepted, the usage row
+	// takes over once the image is inserted
+	defer quota.Release(userName, file.Size, 1)
 
 	// Open the file
 	fileContent, err := file.Open()
 	if err != nil {
-		errChan <- fmt.Errorf("unable to open file %s: %v", file.Filename, err)
-		return
+		return fmt.Errorf("unable to open file %s: %v", file.Filename, err)
 	}
 	defer fileContent.Close()
 
+	// Read the dimensions from the image header, unknown formats are stored as 0x0
+	width, height := 0, 0
+	if imageConfig, _, err := image.DecodeConfig(fileContent); err == nil {
+		width, height = imageConfig.Width, imageConfig.Height
+	}
+	if _, err := fileContent.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to rewind file %s: %v", file.Filename, err)
+	}
+
 	// Set file metadata with the new unique file name
 	fileMetadata := &drive.File{
 		Name: file.Filename,
@@ -34,13 +61,17 @@ func UploadImageToDrive(file *multipart.FileHeader, folderID string, wg *sync.Wa
 	}
 
 	// Upload the file
+	createCtx, span := tracer.Start(ctx, "drive.files.create", trace.WithSpanKind(trace.SpanKindClient))
 	uploadedFile, err := gcs.DriveService.Files.Create(fileMetadata).
 		Media(fileContent). // Upload using the file content as Media
+		Context(createCtx).
 		Do()
+	endSpan(span, err)
 	if err != nil {
-		errChan <- fmt.Errorf("unable to upload file %s: %v", file.Filename, err)
-		return
+		return fmt.Errorf("unable to upload file %s: %v", file.Filename, err)
 	}
+	logger := logging.FromContext(ctx).With("image_id", uploadedFile.Id)
+	logger.Debug("stored file in drive", "width", width, "height", height)
 
 	// Generate the download URL (can be fetched by users for downloading)
 	downloadURL := fmt.Sprintf("https://drive.google.com/uc?export=download&id=%s", uploadedFile.Id)
@@ -48,10 +79,12 @@ func UploadImageToDrive(file *multipart.FileHeader, folderID string, wg *sync.Wa
 	// Wait for the thumbnail to be generated
 	for i := 0; i < 5; i++ {
 		// Fetch the file metadata again to get the thumbnail link
-		fileMetadata, err = gcs.DriveService.Files.Get(uploadedFile.Id).Fields("thumbnailLink").Do()
+		getCtx, span := tracer.Start(ctx, "drive.files.get", trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.Int("attempt", i+1)))
+		fileMetadata, err = gcs.DriveService.Files.Get(uploadedFile.Id).Fields("thumbnailLink").Context(getCtx).Do()
+		endSpan(span, err)
 		if err != nil {
-			errChan <- fmt.Errorf("unable to fetch file metadata for %s: %v", file.Filename, err)
-			return
+			return fmt.Errorf("unable to fetch file metadata for %s: %v", file.Filename, err)
 		}
 
 		if fileMetadata.ThumbnailLink != "" {
@@ -62,11 +95,40 @@ func UploadImageToDrive(file *multipart.FileHeader, folderID string, wg *sync.Wa
 		time.Sleep(1 * time.Second)
 	}
 
-	err = postgressqueries.InsertImageID(uploadedFile.Id, userName, uploadedFile.Name, downloadURL, fileMetadata.ThumbnailLink)
+	if fileMetadata.ThumbnailLink == "" {
+		logger.Warn("thumbnail not ready, storing the image without one")
+	}
+
+	err = postgressqueries.InsertImageID(logging.WithLogger(ctx, logger), uploadedFile.Id, userName, uploadedFile.Name, downloadURL, fileMetadata.ThumbnailLink, width, height, file.Size, metadata)
 	if err != nil {
-		errChan <- err
-		return
+		return err
 	}
 
-	log.Printf("Uploaded file %s with ID: %s", uploadedFile.Name, uploadedFile.Id)
+	logger.Info("uploaded image")
+	return nil
+}
+
+// endSpan ends a storage call span, marking it failed when err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "storage call failed")
+	}
+	span.End()
+}
+
+// DeleteImageFromDrive removes a stored file, a file that is already gone is
+// not an error
+func DeleteImageFromDrive(ctx context.Context, fileID string) error {
+	ctx, span := tracer.Start(ctx, "drive.files.delete", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("image_id", fileID)))
+	err := gcs.DriveService.Files.Delete(fileID).Context(ctx).Do()
+	if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == http.StatusNotFound {
+		err = nil
+	}
+	endSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("unable to delete file %s: %v", fileID, err)
+	}
+	return nil
 }
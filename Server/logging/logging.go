@@ -0,0 +1,58 @@
+// Package logging sets up the structured JSON logger and carries a
+// request-scoped logger through context.Context, so everything logged for one
+// request or upload shares its request ID and user.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var level = new(slog.LevelVar)
+
+// Setup makes a JSON logger writing to stdout the default, the log package
+// then writes through it too
+func Setup(lvl slog.Level) {
+	level.Set(lvl)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})))
+}
+
+// SetLevel changes the level of the default logger, e.g. on config reload
+func SetLevel(lvl slog.Level) {
+	level.Set(lvl)
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger adds the given attributes
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// Fiber returns the logger of the current request
+func Fiber(c *fiber.Ctx) *slog.Logger {
+	return FromContext(c.UserContext())
+}
+
+// AddFiber adds attributes to the logger of the current request
+func AddFiber(c *fiber.Ctx, args ...any) {
+	c.SetUserContext(With(c.UserContext(), args...))
+}
@@ -1,40 +1,103 @@
 package main
 
 import (
+	"MAIN_SERVER/auth"
+	"MAIN_SERVER/components/health"
+	"MAIN_SERVER/config"
 	"MAIN_SERVER/gcs"
+	"MAIN_SERVER/logging"
+	"MAIN_SERVER/metrics"
 	middleware "MAIN_SERVER/middlewares"
 	postgresql "MAIN_SERVER/postgress"
 	postgressqueries "MAIN_SERVER/postgress/queries"
+	"MAIN_SERVER/reaper"
 	worker "MAIN_SERVER/workerpool"
-	"fmt"
+	"context"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+
+	"SHARED_CONFIG/tracing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
 func main() {
+	// Load the config first, every other component reads it
+	cfg, err := config.Load(configPath())
+	if err != nil {
+		slog.Error("unable to load config", "err", err)
+		os.Exit(1)
+	}
+	logging.Setup(cfg.Log.SlogLevel())
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "server", cfg.Tracing)
+	if err != nil {
+		slog.Error("unable to set up tracing", "err", err)
+		os.Exit(1)
+	}
+
 	// Set up Fiber
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		BodyLimit:    cfg.Uploads.MaxRequestBytes,
+		ErrorHandler: middleware.ErrorHandler,
+	})
 
 	// Register middleware
+	app.Use(middleware.Tracing)
 	app.Use(middleware.RequestIDLogger)
+	app.Use(middleware.Metrics)
+	app.Use(middleware.Recover)
+	app.Use(auth.Identify)
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET, POST",
+		// Read per request so reloads apply
+		AllowOriginsFunc: func(origin string) bool {
+			return config.Get().HTTP.AllowsOrigin(origin)
+		},
+		AllowMethods:  cfg.HTTP.AllowMethods(),
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, X-Share-Password, If-None-Match",
+		ExposeHeaders: "ETag, Retry-After, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset",
 	}))
 
-	middleware.LoadConfig("config.json")
+	middleware.LoadRateLimits(app)
 	middleware.LoadRoutes(app)
 
 	postgresql.PostgresDbConnect()
+	if err := postgresql.RunMigrations(); err != nil {
+		slog.Error("unable to apply migrations", "err", err)
+		os.Exit(1)
+	}
 	worker.InitializeWorkerPool()
 
 	// start google drive connection
 	gcs.GetDriveService()
 
+	// start purging soft-deleted images
+	reaper.GetReaper()
+
+	// apply safe config changes on SIGHUP or when the file changes
+	watcher := config.Watch(configPath(), func(cfg *config.Config) {
+		logging.SetLevel(cfg.Log.SlogLevel())
+		middleware.ApplyRateLimits()
+		worker.Resize(cfg.Workers.Uploads)
+	})
+	metrics.RegisterConfigReloads(watcher.Reloads)
+
+	// Serve metrics on their own address, away from clients
+	var metricsApp *fiber.App
+	if cfg.Metrics.Enabled() {
+		metricsApp = metrics.New()
+		go func() {
+			if err := metricsApp.Listen(cfg.Metrics.ListenAddr); err != nil {
+				slog.Error("unable to start metrics server", "err", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	// signal channel to capture system calls
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
@@ -43,15 +106,40 @@ func main() {
 	go func() {
 		// capture sigterm and other system call here
 		<-sigCh
+
+		// fail readiness first so the load balancer drains this instance
+		// while it still serves requests
+		health.StartDraining()
+		drainDelay := config.Get().Health.DrainDelay.Std()
+		slog.Info("draining", "delay", drainDelay.String())
+		time.Sleep(drainDelay)
+
 		postgressqueries.GetLikeCache().Stop()
+		reaper.GetReaper().Stop()
+		watcher.Stop()
 
-		fmt.Println("Shutting down...")
+		slog.Info("shutting down")
 		_ = app.Shutdown()
+		if metricsApp != nil {
+			_ = metricsApp.Shutdown()
+		}
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("flushing traces failed", "err", err)
+		}
 	}()
 
 	// start http server
-	if err := app.Listen("0.0.0.0:3001"); err != nil {
-		fmt.Println("Error starting server:", err)
-		panic(err)
+	if err := app.Listen(cfg.HTTP.ListenAddr); err != nil {
+		slog.Error("unable to start server", "err", err)
+		os.Exit(1)
+	}
+}
+
+// configPath is CONFIG_FILE, config.json by default, YAML files are accepted
+// too
+func configPath() string {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
 	}
+	return "config.json"
 }
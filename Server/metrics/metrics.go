@@ -0,0 +1,104 @@
+// Package metrics defines the Prometheus metrics of the server, served on
+// metrics.listen_addr apart from the API. Packages update the metrics they own, values only known to their
+// owner are registered as gauge functions with RegisterGaugeFunc.
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "uploadkaro"
+
+var (
+	// HTTPRequestDuration observes every request by matched route and status
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// UploadWorkersBusy counts upload workers processing a file
+	UploadWorkersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "upload_workers_busy",
+		Help:      "Upload workers currently processing a file.",
+	})
+
+	// UploadBytes counts the bytes of processed uploads per storage backend
+	UploadBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes of processed uploads by storage backend and outcome.",
+	}, []string{"backend", "outcome"})
+
+	// UploadDuration observes how long storing one file took
+	UploadDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upload_duration_seconds",
+		Help:      "Time to store one uploaded file by storage backend and outcome.",
+		Buckets:   []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 40, 80},
+	}, []string{"backend", "outcome"})
+
+	// LikeFlushErrors counts like counts that failed to persist and were
+	// queued again
+	LikeFlushErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "like_cache_flush_errors_total",
+		Help:      "Like count updates that failed to persist and were retried.",
+	})
+
+	// ThumbnailValidations counts thumbnail checks by outcome: valid,
+	// refreshed or failed
+	ThumbnailValidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "thumbnail_validations_total",
+		Help:      "Thumbnail link checks by outcome.",
+	}, []string{"outcome"})
+)
+
+// RegisterGaugeFunc exposes a value computed on every scrape
+func RegisterGaugeFunc(name, help string, fn func() float64) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+// RegisterConfigReloads exposes the outcomes counted by a config watcher
+func RegisterConfigReloads(reloads func() (succeeded, failed int64)) {
+	for _, result := range []string{"success", "failure"} {
+		prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "config_reloads_total",
+			Help:        "Config reloads by result.",
+			ConstLabels: prometheus.Labels{"result": result},
+		}, func() float64 {
+			succeeded, failed := reloads()
+			if result == "success" {
+				return float64(succeeded)
+			}
+			return float64(failed)
+		}))
+	}
+}
+
+// RegisterDB exposes the connection pool stats of db
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+}
+
+// New returns the app serving the registered metrics on /metrics
+func New() *fiber.App {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+	return app
+}
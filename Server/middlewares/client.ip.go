@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"MAIN_SERVER/auth"
+	"net"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClientIP returns the address of the client. X-Forwarded-For, or Forwarded
+// when it is absent, is only honored when the connection comes from a
+// trusted proxy, and is walked from the right so clients cannot spoof
+// entries added by our own proxies.
+func ClientIP(c *fiber.Ctx) string {
+	remote := c.Context().RemoteIP()
+	ip := auth.TrustedProxies().ClientIP(net.IP(remote), c.Get(fiber.HeaderXForwardedFor), c.Get("Forwarded"))
+	if ip == nil {
+		return remote.String()
+	}
+	return ip.String()
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"MAIN_SERVER/apierror"
+	"MAIN_SERVER/logging"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorHandler answers every failed request with the JSON error envelope.
+// Internal errors are logged with a stack and replaced by a generic message
+// so database and storage errors never reach clients.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	body := apierror.Body{RequestID: RequestID(c)}
+	status := fiber.StatusInternalServerError
+
+	var apiErr *apierror.Error
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.Status
+		body.Code, body.Message, body.Details = apiErr.Code, apiErr.Message, apiErr.Details
+	case errors.As(err, &fiberErr):
+		status = fiberErr.Code
+		body.Code, body.Message = apierror.Code(status), fiberErr.Message
+	default:
+		body.Code, body.Message = apierror.Code(status), "Internal server error"
+	}
+
+	if status >= fiber.StatusInternalServerError {
+		logInternalError(c, err)
+	}
+	return c.Status(status).JSON(apierror.Envelope{Error: body})
+}
+
+// logInternalError logs err with the stack it was raised on, the stack of a
+// panic or else the one of the handler returning it
+func logInternalError(c *fiber.Ctx, err error) {
+	stack := debug.Stack()
+	var panicErr *panicError
+	if errors.As(err, &panicErr) {
+		stack = panicErr.stack
+	}
+
+	logging.Fiber(c).Error("internal error",
+		"err", err,
+		"method", c.Method(),
+		"route", c.Route().Path,
+		"path", c.Path(),
+		"stack", string(stack),
+	)
+}
+
+// panicError is a recovered panic with the stack of the panicking goroutine
+type panicError struct {
+	value any
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// Recover turns panics into internal errors, ErrorHandler logs them
+func Recover(c *fiber.Ctx) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r, stack: debug.Stack()}
+		}
+	}()
+
+	return c.Next()
+}
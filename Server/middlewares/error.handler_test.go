@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"MAIN_SERVER/apierror"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func panickingHandler(*fiber.Ctx) error {
+	panic("boom")
+}
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     fiber.Handler
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+		wantStack   string // Function expected on the logged stack, none is logged when empty
+	}{
+		{
+			name:       "api error",
+			handler:    func(*fiber.Ctx) error { return apierror.New(fiber.StatusConflict, "Taken") },
+			wantStatus: fiber.StatusConflict, wantCode: "conflict", wantMessage: "Taken",
+		},
+		{
+			name:       "fiber error",
+			handler:    func(*fiber.Ctx) error { return fiber.ErrNotFound },
+			wantStatus: fiber.StatusNotFound, wantCode: "not_found", wantMessage: "Not Found",
+		},
+		{
+			name:       "internal error",
+			handler:    func(*fiber.Ctx) error { return errors.New("pq: connection refused") },
+			wantStatus: fiber.StatusInternalServerError, wantCode: "internal", wantMessage: "Internal server error",
+			wantStack: "middlewares.ErrorHandler",
+		},
+		{
+			name:       "unavailable",
+			handler:    func(*fiber.Ctx) error { return fiber.ErrServiceUnavailable },
+			wantStatus: fiber.StatusServiceUnavailable, wantCode: "unavailable", wantMessage: "Service Unavailable",
+			wantStack: "middlewares.ErrorHandler",
+		},
+		{
+			name:       "panic",
+			handler:    panickingHandler,
+			wantStatus: fiber.StatusInternalServerError, wantCode: "internal", wantMessage: "Internal server error",
+			wantStack: "middlewares.panickingHandler",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			defer slog.SetDefault(slog.Default())
+			slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Use(Recover)
+			app.Get("/", test.handler)
+
+			response, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+
+			var envelope apierror.Envelope
+			if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil {
+				t.Fatal(err)
+			}
+			if response.StatusCode != test.wantStatus || envelope.Error.Code != test.wantCode || envelope.Error.Message != test.wantMessage {
+				t.Errorf("got %d %q %q, want %d %q %q", response.StatusCode, envelope.Error.Code, envelope.Error.Message,
+					test.wantStatus, test.wantCode, test.wantMessage)
+			}
+
+			var entry struct {
+				Stack string `json:"stack"`
+			}
+			if logs.Len() > 0 {
+				if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+					t.Fatalf("log is not one JSON entry: %v\n%s", err, logs.String())
+				}
+			}
+			switch {
+			case test.wantStack == "" && logs.Len() > 0:
+				t.Errorf("client error was logged: %s", logs.String())
+			case test.wantStack != "" && !strings.Contains(entry.Stack, test.wantStack):
+				t.Errorf("logged stack does not contain %s:\n%s", test.wantStack, entry.Stack)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"MAIN_SERVER/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// routes are the method and path of every route that is not a middleware
+var routes = map[string]bool{}
+
+// recordRoutes remembers the routes of app, Metrics labels requests that
+// matched none of them "unmatched"
+func recordRoutes(app *fiber.App) {
+	for _, route := range app.GetRoutes(true) {
+		routes[route.Method+" "+route.Path] = true
+	}
+}
+
+// Metrics records the latency of every request by route and status
+func Metrics(c *fiber.Ctx) error {
+	start := time.Now()
+
+	err := c.Next()
+
+	// The last route run is a middleware only when no route matched, those
+	// requests share one label so scanners cannot add series
+	route := c.Route().Path
+	if !routes[c.Route().Method+" "+route] {
+		route = "unmatched"
+	}
+	status := responseStatus(c, err)
+
+	metrics.HTTPRequestDuration.
+		WithLabelValues(c.Method(), route, strconv.Itoa(status)).
+		Observe(time.Since(start).Seconds())
+
+	return err
+}
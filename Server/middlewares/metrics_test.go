@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"MAIN_SERVER/apierror"
+	"MAIN_SERVER/metrics"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMetricsRouteLabel(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(Metrics)
+	app.Get("/metrics-test/images/:id", func(c *fiber.Ctx) error {
+		if c.Params("id") == "missing" {
+			return apierror.New(fiber.StatusNotFound, "Image not found")
+		}
+		return c.SendString("ok")
+	})
+	recordRoutes(app)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantRoute  string
+		wantStatus string
+	}{
+		{name: "matched", method: fiber.MethodGet, path: "/metrics-test/images/1", wantRoute: "/metrics-test/images/:id", wantStatus: "200"},
+		{name: "matched route answering 404", method: fiber.MethodGet, path: "/metrics-test/images/missing", wantRoute: "/metrics-test/images/:id", wantStatus: "404"},
+		{name: "no route", method: fiber.MethodGet, path: "/metrics-test/wp-login.php", wantRoute: "unmatched", wantStatus: "404"},
+		{name: "wrong method", method: fiber.MethodPost, path: "/metrics-test/images/1", wantRoute: "unmatched", wantStatus: "405"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			observer := metrics.HTTPRequestDuration.WithLabelValues(test.method, test.wantRoute, test.wantStatus)
+			before := sampleCount(t, observer)
+
+			response, err := app.Test(httptest.NewRequest(test.method, test.path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+
+			if got := sampleCount(t, observer) - before; got != 1 {
+				t.Errorf("%s %s observed %d times, want once", test.wantRoute, test.wantStatus, got)
+			}
+		})
+	}
+}
+
+func sampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+	t.Helper()
+	var metric dto.Metric
+	if err := observer.(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"MAIN_SERVER/apierror"
+	"MAIN_SERVER/auth"
+	"MAIN_SERVER/config"
+	"MAIN_SERVER/ratelimit"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimitPolicy throttles one route
+type RateLimitPolicy struct {
+	Name   string
+	Method string
+	Path   string
+	ByUser bool   // Also limit the verified user, on top of their client IP
+	Param  string // Also limit each value of this route parameter
+}
+
+// ratePolicies are applied before the routes they protect, policies with
+// the same name share their buckets. Their limit and period come from the
+// rate_limits config.
+var ratePolicies = []RateLimitPolicy{
+	{Name: "upload", Method: fiber.MethodPost, Path: "/image/upload", ByUser: true},
+	{Name: "like", Method: fiber.MethodPost, Path: "/image/like"},
+	{Name: "listing", Method: fiber.MethodPost, Path: "/image/listing"},
+	{Name: "listing", Method: fiber.MethodGet, Path: "/images"},
+	{Name: "search", Method: fiber.MethodGet, Path: "/images/search"},
+	{Name: "share", Method: fiber.MethodGet, Path: "/s/:token", Param: "token"},
+	{Name: "comment", Method: fiber.MethodPost, Path: "/images/:id/comments", ByUser: true},
+}
+
+var (
+	limiters     = make(map[string]*ratelimit.Limiter)
+	limitersLock sync.Mutex
+)
+
+// LoadRateLimits registers the rate limit policies, it must run before
+// LoadRoutes so the limiters see requests first
+func LoadRateLimits(app *fiber.App) {
+	limitersLock.Lock()
+	defer limitersLock.Unlock()
+
+	for _, policy := range ratePolicies {
+		limiter, exists := limiters[policy.Name]
+		if !exists {
+			rate, _ := config.Get().RateLimits.ByName(policy.Name)
+			limiter = ratelimit.NewLimiter(rate.Limit, rate.Period.Std(), rate.Limit)
+			limiters[policy.Name] = limiter
+		}
+		app.Add(policy.Method, policy.Path, rateLimit(policy, limiter))
+	}
+}
+
+// ApplyRateLimits updates the registered limiters to the current config
+func ApplyRateLimits() {
+	limitersLock.Lock()
+	defer limitersLock.Unlock()
+
+	for name, limiter := range limiters {
+		if rate, ok := config.Get().RateLimits.ByName(name); ok {
+			limiter.SetRate(rate.Limit, rate.Period.Std(), rate.Limit)
+		}
+	}
+}
+
+// rateLimit returns a token bucket middleware for a policy. Responses carry
+// RateLimit-* headers, throttled requests get 429 with Retry-After.
+func rateLimit(policy RateLimitPolicy, limiter *ratelimit.Limiter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Every request counts against its client IP and, for per-user
+		// policies, against the user too, so neither many IPs nor many
+		// accounts get around the limit. Per-parameter policies also
+		// protect each target, e.g. a share link from password guessing.
+		keys := []string{"ip:" + ClientIP(c)}
+		if userName := auth.UserName(c); policy.ByUser && userName != "" {
+			keys = append(keys, "user:"+userName)
+		}
+		if policy.Param != "" {
+			keys = append(keys, policy.Param+":"+c.Params(policy.Param))
+		}
+
+		result := limiter.Take(keys...)
+
+		c.Set("RateLimit-Limit", fmt.Sprint(limiter.Burst()))
+		c.Set("RateLimit-Remaining", fmt.Sprint(result.Remaining))
+		c.Set("RateLimit-Reset", fmt.Sprint(seconds(result.Reset)))
+
+		if !result.Allowed {
+			retryAfter := seconds(result.RetryAfter)
+			c.Set(fiber.HeaderRetryAfter, fmt.Sprint(retryAfter))
+			return apierror.New(fiber.StatusTooManyRequests,
+				fmt.Sprintf("Too many %s requests, retry in %d seconds", policy.Name, retryAfter)).
+				WithDetails(fiber.Map{"policy": policy.Name, "retry_after_seconds": retryAfter})
+		}
+
+		return c.Next()
+	}
+}
+
+// seconds rounds a duration up to whole seconds, as the headers require
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -1,23 +1,83 @@
 package middleware
 
 import (
-	"log"
+	"MAIN_SERVER/apierror"
+	"MAIN_SERVER/logging"
+	"errors"
+	"log/slog"
+	"time"
+
+	"SHARED_CONFIG/forwarding"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+const requestIDKey = "request_id"
+
+// probeRoutes are polled by load balancers, their requests are only logged
+// at debug level
+var probeRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// RequestIDLogger tags the request with the X-Request-ID passed on by the
+// load balancer. A missing ID, or one that is unsafe to log, is replaced by a
+// fresh one. A logger carrying the ID goes into the request context, and the
+// request is logged once it completes.
 func RequestIDLogger(c *fiber.Ctx) error {
-	requestID := c.Get("X-Request-ID")
+	start := time.Now()
+
+	requestID := forwarding.RequestID(c.Get(fiber.HeaderXRequestID))
+
+	// set it in the context and echo it to the client
+	c.Locals(requestIDKey, requestID)
+	c.Set(fiber.HeaderXRequestID, requestID)
+	logging.AddFiber(c, "request_id", requestID)
 
-	// set it in the context
-	c.Set("X-Request-ID", requestID)
+	err := c.Next()
+	status := responseStatus(c, err)
 
-	// Log the request details
-	log.Printf("Request ID: %s | Method: %s | URL: %s",
-		requestID,
-		c.Method(),
-		c.OriginalURL(),
+	level := slog.LevelInfo
+	switch {
+	case probeRoutes[c.Route().Path]:
+		level = slog.LevelDebug
+	case status >= fiber.StatusInternalServerError:
+		level = slog.LevelError
+	}
+
+	logging.Fiber(c).LogAttrs(c.UserContext(), level, "request",
+		slog.String("method", c.Method()),
+		slog.String("route", c.Route().Path),
+		slog.String("path", c.Path()),
+		slog.Int("status", status),
+		slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		slog.String("ip", ClientIP(c)),
 	)
 
-	return c.Next()
+	return err
+}
+
+// responseStatus is the status the client gets. Errors are turned into
+// responses after the middlewares return, so it comes from the error when
+// there is one.
+func responseStatus(c *fiber.Ctx, err error) int {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Status
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	if err != nil {
+		return fiber.StatusInternalServerError
+	}
+	return c.Response().StatusCode()
+}
+
+// RequestID returns the ID of the current request
+func RequestID(c *fiber.Ctx) string {
+	requestID, _ := c.Locals(requestIDKey).(string)
+	return requestID
 }
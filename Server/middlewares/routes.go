@@ -1,7 +1,14 @@
 package middleware
 
 import (
+	album "MAIN_SERVER/components/Album"
+	apikey "MAIN_SERVER/components/ApiKey"
+	comment "MAIN_SERVER/components/Comment"
 	image "MAIN_SERVER/components/Image"
+	share "MAIN_SERVER/components/Share"
+	tag "MAIN_SERVER/components/Tag"
+	user "MAIN_SERVER/components/User"
+	"MAIN_SERVER/components/health"
 	"MAIN_SERVER/components/ping"
 
 	"github.com/gofiber/fiber/v2"
@@ -10,6 +17,15 @@ import (
 func LoadRoutes(app *fiber.App) error {
 
 	image.Routes(app)
+	album.Routes(app)
+	share.Routes(app)
+	comment.Routes(app)
+	user.Routes(app)
+	apikey.Routes(app)
+	tag.Routes(app)
 	ping.Routes(app)
+	health.Routes(app)
+
+	recordRoutes(app)
 	return nil
 }
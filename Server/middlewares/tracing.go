@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"MAIN_SERVER/logging"
+	"strconv"
+
+	"SHARED_CONFIG/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("MAIN_SERVER/middlewares")
+
+// Tracing continues the trace of the incoming traceparent header, or starts
+// one, with a server span covering the handler. The span travels in the
+// request context so the upload workers, storage and SQL calls join it.
+func Tracing(c *fiber.Ctx) error {
+	ctx := tracing.Extract(c.UserContext(), &c.Request().Header)
+	ctx, span := tracer.Start(ctx, c.Method(), trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	c.SetUserContext(ctx)
+	if span.SpanContext().IsValid() {
+		logging.AddFiber(c, "trace_id", span.SpanContext().TraceID().String())
+	}
+
+	err := c.Next()
+
+	// The route is only known once the router matched it
+	status := responseStatus(c, err)
+	span.SetName(c.Method() + " " + c.Route().Path)
+	span.SetAttributes(
+		attribute.String("http.request.method", c.Method()),
+		attribute.String("http.route", c.Route().Path),
+		attribute.String("url.path", c.Path()),
+		attribute.Int("http.response.status_code", status),
+		attribute.String("request_id", RequestID(c)),
+	)
+	if status >= fiber.StatusInternalServerError {
+		span.SetStatus(codes.Error, strconv.Itoa(status))
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	return err
+}
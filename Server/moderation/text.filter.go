@@ -0,0 +1,75 @@
+package moderation
+
+import (
+	"MAIN_SERVER/config"
+	"errors"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ErrBlockedText is returned by filters rejecting a text
+var ErrBlockedText = errors.New("text contains blocked words")
+
+// TextFilter decides whether user-written text may be published
+type TextFilter interface {
+	Check(text string) error
+}
+
+// WordListFilter rejects texts containing any of its words, matched as whole
+// words and case-insensitively
+type WordListFilter struct {
+	words map[string]bool
+}
+
+func NewWordListFilter(words []string) *WordListFilter {
+	f := &WordListFilter{words: make(map[string]bool)}
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			f.words[word] = true
+		}
+	}
+	return f
+}
+
+func (f *WordListFilter) Check(text string) error {
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if f.words[word] {
+			return ErrBlockedText
+		}
+	}
+	return nil
+}
+
+var (
+	textFilter     TextFilter
+	textFilterOnce sync.Once
+	textFilterLock sync.RWMutex
+)
+
+// GetTextFilter returns the active filter, by default a word list read from
+// the moderation.blocked_words setting
+func GetTextFilter() TextFilter {
+	textFilterOnce.Do(func() {
+		textFilterLock.Lock()
+		defer textFilterLock.Unlock()
+		if textFilter == nil {
+			textFilter = NewWordListFilter(config.Get().Moderation.BlockedWords)
+		}
+	})
+
+	textFilterLock.RLock()
+	defer textFilterLock.RUnlock()
+	return textFilter
+}
+
+// SetTextFilter replaces the active filter, e.g. with a call to an external
+// moderation service
+func SetTextFilter(filter TextFilter) {
+	textFilterLock.Lock()
+	defer textFilterLock.Unlock()
+	textFilter = filter
+}
@@ -0,0 +1,77 @@
+package postgresql
+
+import (
+	"embed"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// RunMigrations applies every embedded migration that has not been recorded in
+// schema_migrations yet, in file name order.
+func RunMigrations() error {
+	PostgresDbConnect()
+
+	_, err := PostgresConnection.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			name       TEXT PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("unable to create schema_migrations: %v", err)
+	}
+
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return fmt.Errorf("unable to read migrations: %v", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var applied bool
+		err := PostgresConnection.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE name = $1)", name,
+		).Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("unable to check migration %s: %v", name, err)
+		}
+		if applied {
+			continue
+		}
+
+		body, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return fmt.Errorf("unable to read migration %s: %v", name, err)
+		}
+
+		// Apply the migration and record it in the same transaction
+		tx, err := PostgresConnection.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(string(body)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("unable to apply migration %s: %v", name, err)
+		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations (name) VALUES ($1)", name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("unable to record migration %s: %v", name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		slog.Info("applied migration", "name", name)
+	}
+
+	return nil
+}
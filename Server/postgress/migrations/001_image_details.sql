@@ -0,0 +1,9 @@
+-- Dimensions and size captured at upload time, exposed by GET /images/:id
+ALTER TABLE images ADD COLUMN IF NOT EXISTS width      INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS height     INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS size_bytes BIGINT  NOT NULL DEFAULT 0;
+
+-- Keyset pagination for GET /images walks (sort column, id)
+CREATE INDEX IF NOT EXISTS images_liked_count_id_idx ON images (liked_count DESC, id DESC);
+CREATE INDEX IF NOT EXISTS images_created_at_id_idx  ON images (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS images_uploaded_by_idx    ON images (uploaded_by);
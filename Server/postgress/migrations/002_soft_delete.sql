@@ -0,0 +1,5 @@
+-- Soft-deleted images are hidden immediately and purged by the reaper once
+-- the retention window has passed
+ALTER TABLE images ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS images_deleted_at_idx ON images (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,4 @@
+-- User-editable captions shown by the gallery
+ALTER TABLE images ADD COLUMN IF NOT EXISTS title       TEXT NOT NULL DEFAULT '';
+ALTER TABLE images ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE images ADD COLUMN IF NOT EXISTS alt_text    TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,19 @@
+-- Drive file IDs identify images in the API, make them referenceable
+CREATE UNIQUE INDEX IF NOT EXISTS images_image_id_key ON images (image_id);
+
+CREATE TABLE IF NOT EXISTS tags (
+	id         SERIAL PRIMARY KEY,
+	name       TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- text_pattern_ops lets the prefix autocomplete use the index
+CREATE INDEX IF NOT EXISTS tags_name_prefix_idx ON tags (name text_pattern_ops);
+
+CREATE TABLE IF NOT EXISTS image_tags (
+	image_id TEXT    NOT NULL REFERENCES images (image_id) ON DELETE CASCADE,
+	tag_id   INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+	PRIMARY KEY (image_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS image_tags_tag_id_idx ON image_tags (tag_id);
@@ -0,0 +1,21 @@
+-- Tag names are denormalized onto images so the generated search vector can
+-- cover them; setImageTags keeps the column in sync
+ALTER TABLE images ADD COLUMN IF NOT EXISTS tag_names TEXT NOT NULL DEFAULT '';
+
+UPDATE images i
+SET tag_names = COALESCE((
+	SELECT string_agg(t.name, ' ' ORDER BY t.name)
+	FROM image_tags it JOIN tags t ON t.id = it.tag_id
+	WHERE it.image_id = i.image_id
+), '');
+
+ALTER TABLE images ADD COLUMN IF NOT EXISTS search_vector tsvector
+	GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', title), 'A') ||
+		setweight(to_tsvector('simple', tag_names), 'A') ||
+		setweight(to_tsvector('simple', COALESCE(file_name, '')), 'B') ||
+		setweight(to_tsvector('simple', COALESCE(uploaded_by, '')), 'B') ||
+		setweight(to_tsvector('simple', description), 'C')
+	) STORED;
+
+CREATE INDEX IF NOT EXISTS images_search_vector_idx ON images USING GIN (search_vector);
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS albums (
+	id             SERIAL PRIMARY KEY,
+	owner          TEXT NOT NULL,
+	title          TEXT NOT NULL,
+	visibility     TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'unlisted', 'private')),
+	cover_image_id TEXT REFERENCES images (image_id) ON DELETE SET NULL,
+	created_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS albums_owner_idx ON albums (owner);
+
+-- position orders the album, lower first; gaps are allowed
+CREATE TABLE IF NOT EXISTS album_images (
+	album_id INTEGER NOT NULL REFERENCES albums (id) ON DELETE CASCADE,
+	image_id TEXT    NOT NULL REFERENCES images (image_id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	added_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (album_id, image_id)
+);
+
+CREATE INDEX IF NOT EXISTS album_images_position_idx ON album_images (album_id, position);
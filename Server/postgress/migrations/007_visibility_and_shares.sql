@@ -0,0 +1,18 @@
+ALTER TABLE images ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public'
+	CHECK (visibility IN ('public', 'unlisted', 'private'));
+
+-- Share links resolve a signed token to one image; the token itself is
+-- never stored, only its random id
+CREATE TABLE IF NOT EXISTS share_links (
+	id            TEXT PRIMARY KEY,
+	image_id      TEXT NOT NULL REFERENCES images (image_id) ON DELETE CASCADE,
+	created_by    TEXT NOT NULL,
+	expires_at    TIMESTAMP,
+	password_hash TEXT,
+	max_views     INTEGER,
+	view_count    INTEGER NOT NULL DEFAULT 0,
+	revoked_at    TIMESTAMP,
+	created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS share_links_image_id_idx ON share_links (image_id);
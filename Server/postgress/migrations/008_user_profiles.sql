@@ -0,0 +1,8 @@
+-- Profile data that cannot be derived from images; users without a row
+-- still have a profile built from their uploads
+CREATE TABLE IF NOT EXISTS user_profiles (
+	username        TEXT PRIMARY KEY,
+	avatar_image_id TEXT REFERENCES images (image_id) ON DELETE SET NULL,
+	created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
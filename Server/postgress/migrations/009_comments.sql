@@ -0,0 +1,27 @@
+-- parent_id points at a top-level comment, replies cannot be replied to
+CREATE TABLE IF NOT EXISTS comments (
+	id         SERIAL PRIMARY KEY,
+	image_id   TEXT    NOT NULL REFERENCES images (image_id) ON DELETE CASCADE,
+	parent_id  INTEGER REFERENCES comments (id) ON DELETE CASCADE,
+	author     TEXT    NOT NULL,
+	body       TEXT    NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	edited_at  TIMESTAMP,
+	deleted_at TIMESTAMP,
+	deleted_by TEXT
+);
+
+CREATE INDEX IF NOT EXISTS comments_image_id_idx ON comments (image_id, created_at, id) WHERE parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS comments_parent_id_idx ON comments (parent_id, created_at, id);
+
+-- User reports of images and comments awaiting a moderator
+CREATE TABLE IF NOT EXISTS reports (
+	id          SERIAL PRIMARY KEY,
+	target_type TEXT NOT NULL CHECK (target_type IN ('image', 'comment')),
+	target_id   TEXT NOT NULL,
+	reporter    TEXT NOT NULL,
+	reason      TEXT NOT NULL DEFAULT '',
+	created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	resolved_at TIMESTAMP,
+	UNIQUE (target_type, target_id, reporter)
+);
@@ -0,0 +1,19 @@
+-- Bytes and files held by each uploader, soft-deleted images excluded
+CREATE TABLE IF NOT EXISTS user_usage (
+	username     TEXT PRIMARY KEY,
+	bytes_stored BIGINT  NOT NULL DEFAULT 0,
+	file_count   INTEGER NOT NULL DEFAULT 0,
+	updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO user_usage (username, bytes_stored, file_count)
+SELECT uploaded_by, SUM(size_bytes), COUNT(*)
+FROM images
+WHERE deleted_at IS NULL AND uploaded_by IS NOT NULL
+GROUP BY uploaded_by
+ON CONFLICT (username) DO NOTHING;
+
+-- Quota tier and optional per-user overrides of the tier limits
+ALTER TABLE user_profiles ADD COLUMN IF NOT EXISTS tier        TEXT NOT NULL DEFAULT 'free';
+ALTER TABLE user_profiles ADD COLUMN IF NOT EXISTS quota_bytes BIGINT;
+ALTER TABLE user_profiles ADD COLUMN IF NOT EXISTS quota_files INTEGER;
@@ -0,0 +1,15 @@
+-- Only a SHA-256 of each key is stored, the key itself is shown once
+CREATE TABLE IF NOT EXISTS api_keys (
+	id           SERIAL PRIMARY KEY,
+	username     TEXT   NOT NULL,
+	name         TEXT   NOT NULL,
+	prefix       TEXT   NOT NULL,
+	key_hash     TEXT   NOT NULL UNIQUE,
+	scopes       TEXT[] NOT NULL,
+	expires_at   TIMESTAMP,
+	created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_used_at TIMESTAMP,
+	revoked_at   TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_username_idx ON api_keys (username);
@@ -0,0 +1,3 @@
+-- Anonymous uploads were counted under an empty user name, they belong to
+-- no one and no longer count against any quota
+DELETE FROM user_usage WHERE username = '';
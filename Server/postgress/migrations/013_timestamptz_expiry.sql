@@ -0,0 +1,10 @@
+-- Expiry times were written from Go with their zone dropped, so a server
+-- whose zone differs from the database session's expired links and keys
+-- early or late. Existing values are read in the session zone, like before.
+ALTER TABLE share_links
+	ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN revoked_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE api_keys
+	ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN revoked_at TYPE TIMESTAMPTZ;
@@ -1,11 +1,19 @@
 package postgresql
 
 import (
+	"MAIN_SERVER/config"
+	"MAIN_SERVER/metrics"
+	"context"
 	"database/sql"
-	"log"
+	"database/sql/driver"
+	"log/slog"
+	"os"
 	"sync"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -15,22 +23,39 @@ var (
 
 func PostgresDbConnect() {
 
-	// Build the DSN (Data Source Name) string
-	connStr := "a"
+	// The DSN (Data Source Name) comes from database.dsn or DATABASE_URL
+	dbConfig := config.Get().Database
 
 	// Initialize the database connection once using sync.Once
 	once.Do(func() {
 		var err error
-		PostgresConnection, err = sql.Open("postgres", connStr)
+		// Queries made with a traced context get a span each, others are
+		// left out rather than starting traces of their own
+		PostgresConnection, err = otelsql.Open("postgres", dbConfig.DSN,
+			otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+			otelsql.WithSpanOptions(otelsql.SpanOptions{
+				OmitConnResetSession: true,
+				OmitRows:             true,
+				SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+					return trace.SpanContextFromContext(ctx).IsValid()
+				},
+			}),
+		)
 		if err != nil {
-			log.Fatal(err)
+			slog.Error("unable to open database", "err", err)
+			os.Exit(1)
 		}
+		PostgresConnection.SetMaxOpenConns(dbConfig.MaxOpenConns)
+		PostgresConnection.SetMaxIdleConns(dbConfig.MaxIdleConns)
 
 		// Ensure the database is reachable
 		err = PostgresConnection.Ping()
 		if err != nil {
-			log.Fatal(err)
+			slog.Error("unable to reach database", "err", err)
+			os.Exit(1)
 		}
-		log.Println("PostgreSQL connection established successfully")
+		slog.Info("PostgreSQL connection established successfully")
+
+		metrics.RegisterDB(PostgresConnection)
 	})
 }
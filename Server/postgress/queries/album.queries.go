@@ -0,0 +1,222 @@
+package postgressqueries
+
+import (
+	albumdto "MAIN_SERVER/components/Album/dto"
+	"MAIN_SERVER/components/Image/dto"
+	postgresql "MAIN_SERVER/postgress"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const albumColumns = `a.id, a.owner, a.title, a.visibility, COALESCE(a.cover_image_id, ''),
+	COALESCE((SELECT thumbnail_link FROM images WHERE image_id = a.cover_image_id
+		AND is_approved = 1 AND deleted_at IS NULL AND visibility <> 'private'), ''),
+	(SELECT COUNT(*) FROM album_images ai JOIN images i ON i.image_id = ai.image_id
+		WHERE ai.album_id = a.id AND i.deleted_at IS NULL),
+	a.created_at, a.updated_at`
+
+func albumFields(album *albumdto.AlbumResponse) []any {
+	return []any{&album.ID, &album.Owner, &album.Title, &album.Visibility, &album.CoverImageID,
+		&album.CoverThumbnail, &album.ImageCount, &album.CreatedAt, &album.UpdatedAt}
+}
+
+func CreateAlbum(ctx context.Context, owner string, title string, visibility string, coverImageID string) (*albumdto.AlbumResponse, error) {
+	var albumID int64
+	err := postgresql.PostgresConnection.QueryRowContext(ctx, `
+		INSERT INTO albums (owner, title, visibility, cover_image_id)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING id
+	`, owner, title, visibility, coverImageID).Scan(&albumID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create album: %v", err)
+	}
+
+	return GetAlbum(ctx, albumID)
+}
+
+// GetAlbum returns sql.ErrNoRows when the album does not exist
+func GetAlbum(ctx context.Context, albumID int64) (*albumdto.AlbumResponse, error) {
+	var album albumdto.AlbumResponse
+	err := postgresql.PostgresConnection.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT %s FROM albums a WHERE a.id = $1
+	`, albumColumns), albumID).Scan(albumFields(&album)...)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to query album %d: %v", albumID, err)
+	}
+	return &album, nil
+}
+
+// UpdateAlbum applies the non-nil fields of patch, an empty cover image ID
+// clears the cover
+func UpdateAlbum(ctx context.Context, albumID int64, patch albumdto.AlbumUpdateReqDto) error {
+	assignments := []string{"updated_at = CURRENT_TIMESTAMP"}
+	args := []any{}
+
+	if patch.Title != nil {
+		args = append(args, *patch.Title)
+		assignments = append(assignments, fmt.Sprintf("title = $%d", len(args)))
+	}
+	if patch.Visibility != nil {
+		args = append(args, *patch.Visibility)
+		assignments = append(assignments, fmt.Sprintf("visibility = $%d", len(args)))
+	}
+	if patch.CoverImageID != nil {
+		args = append(args, *patch.CoverImageID)
+		assignments = append(assignments, fmt.Sprintf("cover_image_id = NULLIF($%d, '')", len(args)))
+	}
+
+	args = append(args, albumID)
+	result, err := postgresql.PostgresConnection.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE albums SET %s WHERE id = $%d
+	`, strings.Join(assignments, ", "), len(args)), args...)
+	if err != nil {
+		return fmt.Errorf("unable to update album %d: %v", albumID, err)
+	}
+	return requireAffected(result)
+}
+
+func DeleteAlbum(ctx context.Context, albumID int64) error {
+	result, err := postgresql.PostgresConnection.ExecContext(ctx, "DELETE FROM albums WHERE id = $1", albumID)
+	if err != nil {
+		return fmt.Errorf("unable to delete album %d: %v", albumID, err)
+	}
+	return requireAffected(result)
+}
+
+// addAlbumImages appends images to the end of an album in the given order.
+// Only images uploaded by owner or approved non-private ones are added, images
+// already in the album keep their position. Returns the number added.
+func addAlbumImages(ctx context.Context, db execer, albumID int64, owner string, imageIDs []string) (int64, error) {
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO album_images (album_id, image_id, position)
+		SELECT $1, x.image_id,
+			(SELECT COALESCE(MAX(position), 0) FROM album_images WHERE album_id = $1) + x.ord
+		FROM unnest($2::text[]) WITH ORDINALITY AS x(image_id, ord)
+		JOIN images i ON i.image_id = x.image_id
+		WHERE i.deleted_at IS NULL
+		  AND (i.uploaded_by = $3 OR (i.is_approved = 1 AND i.visibility <> 'private'))
+		ON CONFLICT (album_id, image_id) DO NOTHING
+	`, albumID, pq.Array(imageIDs), owner)
+	if err != nil {
+		return 0, fmt.Errorf("unable to add images to album %d: %v", albumID, err)
+	}
+	return result.RowsAffected()
+}
+
+func AddAlbumImages(ctx context.Context, albumID int64, owner string, imageIDs []string) (int64, error) {
+	added, err := addAlbumImages(ctx, postgresql.PostgresConnection, albumID, owner, imageIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = postgresql.PostgresConnection.ExecContext(ctx, "UPDATE albums SET updated_at = CURRENT_TIMESTAMP WHERE id = $1", albumID)
+	return added, err
+}
+
+// RemoveAlbumImage returns sql.ErrNoRows when the image is not in the album
+func RemoveAlbumImage(ctx context.Context, albumID int64, imageID string) error {
+	result, err := postgresql.PostgresConnection.ExecContext(ctx,
+		"DELETE FROM album_images WHERE album_id = $1 AND image_id = $2", albumID, imageID,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to remove image %s from album %d: %v", imageID, albumID, err)
+	}
+	return requireAffected(result)
+}
+
+// ReorderAlbumImages sets the album order to imageIDs, which must list every
+// image of the album that is not soft-deleted exactly once. Returns false when
+// it does not.
+func ReorderAlbumImages(ctx context.Context, albumID int64, imageIDs []string) (bool, error) {
+	tx, err := postgresql.PostgresConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Lock the membership so concurrent adds cannot slip in between the
+	// check and the update. Soft-deleted images are not listed, so they do
+	// not have to be ordered either.
+	var members, matched int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE image_id = ANY($2::text[]))
+		FROM (
+			SELECT ai.image_id FROM album_images ai
+			JOIN images i ON i.image_id = ai.image_id
+			WHERE ai.album_id = $1 AND i.deleted_at IS NULL
+			FOR UPDATE OF ai
+		) m
+	`, albumID, pq.Array(imageIDs)).Scan(&members, &matched)
+	if err != nil {
+		return false, fmt.Errorf("unable to read album %d: %v", albumID, err)
+	}
+	if members != len(imageIDs) || matched != len(imageIDs) {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE album_images ai
+		SET position = x.ord
+		FROM unnest($2::text[]) WITH ORDINALITY AS x(image_id, ord), images i
+		WHERE ai.album_id = $1 AND ai.image_id = x.image_id
+		  AND i.image_id = ai.image_id AND i.deleted_at IS NULL
+	`, albumID, pq.Array(imageIDs))
+	if err != nil {
+		return false, fmt.Errorf("unable to reorder album %d: %v", albumID, err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE albums SET updated_at = CURRENT_TIMESTAMP WHERE id = $1", albumID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// ListAlbumImages returns an album's images in album order. Only approved,
+// non-private images are included, except that owner also sees their own
+// uploads. owner is empty unless the album owner is the one listing.
+func ListAlbumImages(ctx context.Context, albumID int64, owner string, cursor string, limit int) ([]dto.FileResponse, string, error) {
+	q := dto.ImageListQueryDto{Limit: limit}
+	NormalizeListQuery(&q)
+
+	conditions := []string{"ai.album_id = $1", "i.deleted_at IS NULL"}
+	args := []any{albumID}
+
+	// Images of other users may have been made private or rejected since
+	// they were added, the owner must not see those either
+	if owner != "" {
+		args = append(args, owner)
+		conditions = append(conditions, fmt.Sprintf("(i.uploaded_by = $%d OR (i.is_approved = 1 AND i.visibility <> 'private'))", len(args)))
+	} else {
+		conditions = append(conditions, "i.is_approved = 1", "i.visibility <> 'private'")
+	}
+
+	if cursor != "" {
+		decoded, err := decodeCursor(cursor, "integer")
+		if err != nil {
+			return nil, "", err
+		}
+		args = append(args, decoded.Value, decoded.ID)
+		conditions = append(conditions, fmt.Sprintf("(ai.position, i.id) > ($%d::integer, $%d)", len(args)-1, len(args)))
+	}
+
+	args = append(args, q.Limit+1)
+	query := fmt.Sprintf(`
+		SELECT %s, ai.position, i.id
+		FROM album_images ai
+		JOIN images i ON i.image_id = ai.image_id
+		WHERE %s
+		ORDER BY ai.position, i.id
+		LIMIT $%d
+	`, fileResponseColumns, strings.Join(conditions, " AND "), len(args))
+
+	return queryFileResponsePage(ctx, query, args, q.Limit, nil)
+}
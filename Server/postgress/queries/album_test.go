@@ -0,0 +1,162 @@
+package postgressqueries
+
+import (
+	"MAIN_SERVER/config"
+	postgresql "MAIN_SERVER/postgress"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The tests in this file need a disposable Postgres database in
+// TEST_DATABASE_URL and are skipped without one
+
+var (
+	testDatabaseOnce sync.Once
+	testDatabaseErr  error
+	testNames        atomic.Int64
+)
+
+func testDatabase(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	testDatabaseOnce.Do(func() {
+		os.Setenv("DATABASE_URL", dsn)
+		os.Setenv("FOLDER_ID", "test")
+		if _, testDatabaseErr = config.Load(""); testDatabaseErr != nil {
+			return
+		}
+		postgresql.PostgresDbConnect()
+
+		// The images table predates the migrations
+		_, testDatabaseErr = postgresql.PostgresConnection.Exec(`
+			CREATE TABLE IF NOT EXISTS images (
+				id                SERIAL PRIMARY KEY,
+				image_id          TEXT NOT NULL,
+				created_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				liked_count       BIGINT NOT NULL DEFAULT 0,
+				uploaded_by       TEXT,
+				file_name         TEXT,
+				download_url      TEXT,
+				thumbnail_link    TEXT,
+				is_approved       INTEGER NOT NULL DEFAULT 0,
+				marked_for_review INTEGER NOT NULL DEFAULT 0
+			)
+		`)
+		if testDatabaseErr != nil {
+			return
+		}
+		testDatabaseErr = postgresql.RunMigrations()
+	})
+	if testDatabaseErr != nil {
+		t.Fatal(testDatabaseErr)
+	}
+}
+
+// uniqueName keeps the rows of a run apart from those of earlier runs
+func uniqueName(prefix string) string {
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano(), testNames.Add(1))
+}
+
+// insertImage stores an approved public image of uploadedBy
+func insertImage(t *testing.T, uploadedBy string) string {
+	t.Helper()
+	imageID := uniqueName("image")
+	_, err := postgresql.PostgresConnection.Exec(`
+		INSERT INTO images (image_id, uploaded_by, file_name, download_url, thumbnail_link, is_approved)
+		VALUES ($1, $2, $1, '', '', 1)
+	`, imageID, uploadedBy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return imageID
+}
+
+func setImage(t *testing.T, imageID string, assignment string) {
+	t.Helper()
+	if _, err := postgresql.PostgresConnection.Exec("UPDATE images SET "+assignment+" WHERE image_id = $1", imageID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// albumOf creates an album of owner holding imageIDs in that order
+func albumOf(t *testing.T, owner string, imageIDs ...string) int64 {
+	t.Helper()
+	ctx := context.Background()
+	album, err := CreateAlbum(ctx, owner, "test", "public", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added, err := AddAlbumImages(ctx, album.ID, owner, imageIDs); err != nil || added != int64(len(imageIDs)) {
+		t.Fatalf("AddAlbumImages() = %d, %v, want %d added", added, err, len(imageIDs))
+	}
+	return album.ID
+}
+
+func listedIDs(t *testing.T, albumID int64, owner string) []string {
+	t.Helper()
+	files, _, err := ListAlbumImages(context.Background(), albumID, owner, "", MaxListLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]string, len(files))
+	for i, file := range files {
+		ids[i] = file.ID
+	}
+	return ids
+}
+
+func TestListAlbumImagesHidesOthersImagesMadePrivate(t *testing.T) {
+	testDatabase(t)
+	owner, other := uniqueName("owner"), uniqueName("other")
+
+	own := insertImage(t, owner)
+	theirs := insertImage(t, other)
+	rejected := insertImage(t, other)
+	albumID := albumOf(t, owner, own, theirs, rejected)
+
+	// Everything changes after the images were added
+	setImage(t, own, "visibility = 'private', is_approved = 0")
+	setImage(t, theirs, "visibility = 'private'")
+	setImage(t, rejected, "is_approved = 0")
+
+	if got := listedIDs(t, albumID, owner); !slices.Equal(got, []string{own}) {
+		t.Errorf("owner lists %v, want only their own image %s", got, own)
+	}
+	if got := listedIDs(t, albumID, ""); len(got) != 0 {
+		t.Errorf("others list %v, want nothing", got)
+	}
+}
+
+func TestReorderAlbumImagesIgnoresDeletedImages(t *testing.T) {
+	testDatabase(t)
+	ctx := context.Background()
+	owner := uniqueName("owner")
+
+	first, deleted, last := insertImage(t, owner), insertImage(t, owner), insertImage(t, owner)
+	albumID := albumOf(t, owner, first, deleted, last)
+	setImage(t, deleted, "deleted_at = CURRENT_TIMESTAMP")
+
+	ok, err := ReorderAlbumImages(ctx, albumID, []string{last, first})
+	if err != nil || !ok {
+		t.Fatalf("ReorderAlbumImages() without the deleted image = %v, %v, want true", ok, err)
+	}
+	if got := listedIDs(t, albumID, owner); !slices.Equal(got, []string{last, first}) {
+		t.Errorf("album order = %v, want %v", got, []string{last, first})
+	}
+
+	ok, err = ReorderAlbumImages(ctx, albumID, []string{deleted, first, last})
+	if err != nil || ok {
+		t.Errorf("ReorderAlbumImages() with the deleted image = %v, %v, want false", ok, err)
+	}
+}
@@ -0,0 +1,101 @@
+package postgressqueries
+
+import (
+	apikeydto "MAIN_SERVER/components/ApiKey/dto"
+	postgresql "MAIN_SERVER/postgress"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = `id, name, prefix, scopes, expires_at, created_at, last_used_at`
+
+func apiKeyFields(key *apikeydto.ApiKeyResponse) []any {
+	return []any{&key.ID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.ExpiresAt, &key.CreatedAt, &key.LastUsedAt}
+}
+
+func CreateApiKey(ctx context.Context, userName string, name string, prefix string, keyHash string, scopes []string, expiresAt *time.Time) (*apikeydto.ApiKeyResponse, error) {
+	var key apikeydto.ApiKeyResponse
+	err := postgresql.PostgresConnection.QueryRowContext(ctx, fmt.Sprintf(`
+		INSERT INTO api_keys (username, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING %s
+	`, apiKeyColumns), userName, name, prefix, keyHash, pq.Array(scopes), expiresAt).Scan(apiKeyFields(&key)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create api key: %v", err)
+	}
+	return &key, nil
+}
+
+// ListApiKeys returns the live keys of a user, newest first
+func ListApiKeys(ctx context.Context, userName string) ([]apikeydto.ApiKeyResponse, error) {
+	rows, err := postgresql.PostgresConnection.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM api_keys
+		WHERE username = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC, id DESC
+	`, apiKeyColumns), userName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query api keys: %v", err)
+	}
+	defer rows.Close()
+
+	keys := []apikeydto.ApiKeyResponse{}
+	for rows.Next() {
+		var key apikeydto.ApiKeyResponse
+		if err := rows.Scan(apiKeyFields(&key)...); err != nil {
+			return nil, fmt.Errorf("unable to scan row: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeApiKey returns sql.ErrNoRows when the user has no such live key
+func RevokeApiKey(ctx context.Context, userName string, keyID int64) error {
+	result, err := postgresql.PostgresConnection.ExecContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND username = $2 AND revoked_at IS NULL
+	`, keyID, userName)
+	if err != nil {
+		return fmt.Errorf("unable to revoke api key %d: %v", keyID, err)
+	}
+	return requireAffected(result)
+}
+
+// LookupApiKey resolves the hash of a presented key to its owner and scopes.
+// Revoked and expired keys return sql.ErrNoRows.
+func LookupApiKey(ctx context.Context, keyHash string) (int64, string, []string, error) {
+	var keyID int64
+	var userName string
+	var scopes []string
+
+	err := postgresql.PostgresConnection.QueryRowContext(ctx, `
+		SELECT id, username, scopes
+		FROM api_keys
+		WHERE key_hash = $1
+		  AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+	`, keyHash).Scan(&keyID, &userName, pq.Array(&scopes))
+	if err == sql.ErrNoRows {
+		return 0, "", nil, err
+	}
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("unable to query api key: %v", err)
+	}
+	return keyID, userName, scopes, nil
+}
+
+// TouchApiKey records a use of a key, skipping the write when another server
+// recorded one within the last minute
+func TouchApiKey(ctx context.Context, keyID int64) error {
+	_, err := postgresql.PostgresConnection.ExecContext(ctx, `
+		UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')
+	`, keyID)
+	return err
+}
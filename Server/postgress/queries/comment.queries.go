@@ -0,0 +1,193 @@
+package postgressqueries
+
+import (
+	commentdto "MAIN_SERVER/components/Comment/dto"
+	postgresql "MAIN_SERVER/postgress"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Deleted comments keep their place in the thread but lose author and body
+const commentColumns = `c.id, c.image_id, COALESCE(c.parent_id, 0),
+	CASE WHEN c.deleted_at IS NULL THEN c.author ELSE '' END,
+	CASE WHEN c.deleted_at IS NULL THEN c.body ELSE '' END,
+	c.created_at, c.edited_at, c.deleted_at IS NOT NULL`
+
+func commentFields(comment *commentdto.CommentResponse) []any {
+	return []any{&comment.ID, &comment.ImageID, &comment.ParentID, &comment.Author, &comment.Body,
+		&comment.CreatedAt, &comment.EditedAt, &comment.Deleted}
+}
+
+func CreateComment(ctx context.Context, imageID string, parentID int64, author string, body string) (*commentdto.CommentResponse, error) {
+	var commentID int64
+	err := postgresql.PostgresConnection.QueryRowContext(ctx, `
+		INSERT INTO comments (image_id, parent_id, author, body)
+		VALUES ($1, NULLIF($2, 0), $3, $4)
+		RETURNING id
+	`, imageID, parentID, author, body).Scan(&commentID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create comment: %v", err)
+	}
+
+	return GetComment(ctx, commentID)
+}
+
+// GetComment returns sql.ErrNoRows when the comment does not exist
+func GetComment(ctx context.Context, commentID int64) (*commentdto.CommentResponse, error) {
+	var comment commentdto.CommentResponse
+	err := postgresql.PostgresConnection.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT %s FROM comments c WHERE c.id = $1
+	`, commentColumns), commentID).Scan(commentFields(&comment)...)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to query comment %d: %v", commentID, err)
+	}
+	return &comment, nil
+}
+
+// GetCommentAuthor returns the author of a live comment
+func GetCommentAuthor(ctx context.Context, commentID int64) (string, error) {
+	var author string
+	err := postgresql.PostgresConnection.QueryRowContext(ctx,
+		"SELECT author FROM comments WHERE id = $1 AND deleted_at IS NULL", commentID,
+	).Scan(&author)
+	return author, err
+}
+
+// UpdateCommentBody returns sql.ErrNoRows when the comment is missing or
+// deleted
+func UpdateCommentBody(ctx context.Context, commentID int64, body string) error {
+	result, err := postgresql.PostgresConnection.ExecContext(ctx, `
+		UPDATE comments
+		SET body = $1, edited_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND deleted_at IS NULL
+	`, body, commentID)
+	if err != nil {
+		return fmt.Errorf("unable to update comment %d: %v", commentID, err)
+	}
+	return requireAffected(result)
+}
+
+// SoftDeleteComment hides a comment's body, keeping its replies in place
+func SoftDeleteComment(ctx context.Context, commentID int64, deletedBy string) error {
+	result, err := postgresql.PostgresConnection.ExecContext(ctx, `
+		UPDATE comments
+		SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $1
+		WHERE id = $2 AND deleted_at IS NULL
+	`, deletedBy, commentID)
+	if err != nil {
+		return fmt.Errorf("unable to delete comment %d: %v", commentID, err)
+	}
+	return requireAffected(result)
+}
+
+// ListComments returns a page of top-level comments of an image, oldest
+// first, each with all of its replies
+func ListComments(ctx context.Context, imageID string, cursor string, limit int) ([]commentdto.CommentResponse, string, error) {
+	conditions := []string{"c.image_id = $1", "c.parent_id IS NULL"}
+	args := []any{imageID}
+
+	if cursor != "" {
+		decoded, err := decodeCursor(cursor, "timestamp")
+		if err != nil {
+			return nil, "", err
+		}
+		args = append(args, decoded.Value, decoded.ID)
+		conditions = append(conditions, fmt.Sprintf("(c.created_at, c.id) > ($%d::timestamp, $%d)", len(args)-1, len(args)))
+	}
+
+	args = append(args, limit+1)
+	rows, err := postgresql.PostgresConnection.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM comments c
+		WHERE %s
+		ORDER BY c.created_at, c.id
+		LIMIT $%d
+	`, commentColumns, strings.Join(conditions, " AND "), len(args)), args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to query comments: %v", err)
+	}
+	defer rows.Close()
+
+	comments := []commentdto.CommentResponse{}
+	more := false
+	for rows.Next() {
+		var comment commentdto.CommentResponse
+		if err := rows.Scan(commentFields(&comment)...); err != nil {
+			return nil, "", fmt.Errorf("unable to scan row: %v", err)
+		}
+		if len(comments) == limit {
+			more = true
+			break
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("row iteration error: %v", err)
+	}
+
+	if err := attachReplies(ctx, comments); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if more {
+		last := comments[len(comments)-1]
+		nextCursor = encodeCursor(listCursor{Value: cursorValue(last.CreatedAt), ID: last.ID})
+	}
+	return comments, nextCursor, nil
+}
+
+// attachReplies loads the replies of every comment in one query
+func attachReplies(ctx context.Context, comments []commentdto.CommentResponse) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	index := make(map[int64]int, len(comments))
+	parentIDs := make([]int64, len(comments))
+	for i, comment := range comments {
+		index[comment.ID] = i
+		parentIDs[i] = comment.ID
+	}
+
+	rows, err := postgresql.PostgresConnection.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM comments c
+		WHERE c.parent_id = ANY($1::integer[])
+		ORDER BY c.created_at, c.id
+	`, commentColumns), pq.Array(parentIDs))
+	if err != nil {
+		return fmt.Errorf("unable to query replies: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reply commentdto.CommentResponse
+		if err := rows.Scan(commentFields(&reply)...); err != nil {
+			return fmt.Errorf("unable to scan row: %v", err)
+		}
+		parent := &comments[index[reply.ParentID]]
+		parent.Replies = append(parent.Replies, reply)
+	}
+	return rows.Err()
+}
+
+// CreateReport records a report, reporting the same target twice is a no-op
+func CreateReport(ctx context.Context, targetType string, targetID string, reporter string, reason string) error {
+	_, err := postgresql.PostgresConnection.ExecContext(ctx, `
+		INSERT INTO reports (target_type, target_id, reporter, reason)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (target_type, target_id, reporter) DO NOTHING
+	`, targetType, targetID, reporter, reason)
+	if err != nil {
+		return fmt.Errorf("unable to report %s %s: %v", targetType, targetID, err)
+	}
+	return nil
+}
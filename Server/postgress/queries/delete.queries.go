@@ -0,0 +1,113 @@
+package postgressqueries
+
+import (
+	postgresql "MAIN_SERVER/postgress"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SoftDeleteImage hides an image from every listing and releases its quota
+// usage. Returns sql.ErrNoRows when the image does not exist or is already
+// deleted.
+func SoftDeleteImage(ctx context.Context, imageID string) error {
+	return updateDeletedState(ctx, imageID, `
+		UPDATE images
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE image_id = $1 AND deleted_at IS NULL
+		RETURNING COALESCE(uploaded_by, ''), size_bytes
+	`, []any{imageID}, -1)
+}
+
+// RestoreImage undoes a soft delete as long as the reaper has not purged the
+// image yet, charging its usage back to the uploader
+func RestoreImage(ctx context.Context, imageID string, retention time.Duration) error {
+	return updateDeletedState(ctx, imageID, `
+		UPDATE images
+		SET deleted_at = NULL
+		WHERE image_id = $1
+		  AND deleted_at IS NOT NULL
+		  AND deleted_at > CURRENT_TIMESTAMP - make_interval(secs => $2)
+		RETURNING COALESCE(uploaded_by, ''), size_bytes
+	`, []any{imageID, retention.Seconds()}, 1)
+}
+
+// updateDeletedState runs a soft delete or restore query returning the
+// uploader and size, and adjusts the uploader's usage by sign times the size
+// in the same transaction
+func updateDeletedState(ctx context.Context, imageID string, query string, args []any, sign int) error {
+	tx, err := postgresql.PostgresConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var uploadedBy string
+	var sizeBytes int64
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&uploadedBy, &sizeBytes)
+	if err == sql.ErrNoRows {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("unable to update image %s: %v", imageID, err)
+	}
+
+	if err := adjustUsage(ctx, tx, uploadedBy, int64(sign)*sizeBytes, sign); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListReapableImages returns up to limit image IDs whose retention window
+// has expired
+func ListReapableImages(ctx context.Context, retention time.Duration, limit int) ([]string, error) {
+	rows, err := postgresql.PostgresConnection.QueryContext(ctx, `
+		SELECT image_id
+		FROM images
+		WHERE deleted_at IS NOT NULL
+		  AND deleted_at <= CURRENT_TIMESTAMP - make_interval(secs => $1)
+		ORDER BY deleted_at
+		LIMIT $2
+	`, retention.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query deleted images: %v", err)
+	}
+	defer rows.Close()
+
+	var imageIDs []string
+	for rows.Next() {
+		var imageID string
+		if err := rows.Scan(&imageID); err != nil {
+			return nil, fmt.Errorf("unable to scan row: %v", err)
+		}
+		imageIDs = append(imageIDs, imageID)
+	}
+
+	return imageIDs, rows.Err()
+}
+
+// PurgeImage removes a soft-deleted image row for good
+func PurgeImage(ctx context.Context, imageID string) error {
+	_, err := postgresql.PostgresConnection.ExecContext(ctx,
+		"DELETE FROM images WHERE image_id = $1 AND deleted_at IS NOT NULL",
+		imageID,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to purge image %s: %v", imageID, err)
+	}
+	return nil
+}
+
+// requireAffected maps an update that matched no row to sql.ErrNoRows
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
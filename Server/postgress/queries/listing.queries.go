@@ -0,0 +1,267 @@
+package postgressqueries
+
+import (
+	"MAIN_SERVER/components/Image/dto"
+	postgresql "MAIN_SERVER/postgress"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// ErrInvalidCursor is returned when a listing cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// publicImageCondition matches images that may appear in public listings
+const publicImageCondition = "i.is_approved = 1 AND i.deleted_at IS NULL AND i.visibility = 'public'"
+
+// moderationStatusColumn derives the moderation status written by the NSFW
+// processor into is_approved and marked_for_review
+const moderationStatusColumn = `CASE
+		WHEN i.is_approved = 1 THEN 'approved'
+		WHEN i.is_approved = 2 THEN 'rejected'
+		WHEN i.marked_for_review = 1 THEN 'in_review'
+		ELSE 'pending'
+	END`
+
+// fileResponseColumns are the columns scanned by fileResponseFields, in order
+const fileResponseColumns = `i.image_id, i.file_name, i.thumbnail_link, i.liked_count, i.download_url,
+	i.title, i.description, i.alt_text,
+	ARRAY(SELECT t.name FROM image_tags it JOIN tags t ON t.id = it.tag_id WHERE it.image_id = i.image_id ORDER BY t.name),
+	(SELECT COUNT(*) FROM comments c WHERE c.image_id = i.image_id AND c.deleted_at IS NULL)`
+
+// fileResponseFields returns the scan destinations matching fileResponseColumns
+func fileResponseFields(file *dto.FileResponse) []any {
+	return []any{&file.ID, &file.Name, &file.Thumbnail, &file.LikedCount, &file.DownloadURL,
+		&file.Title, &file.Description, &file.AltText, pq.Array(&file.Tags), &file.CommentCount}
+}
+
+// listSort describes a sort key usable for keyset pagination
+type listSort struct {
+	column  string
+	sqlType string
+}
+
+var listSorts = map[string]listSort{
+	"liked_count": {column: "i.liked_count", sqlType: "bigint"},
+	"created_at":  {column: "i.created_at", sqlType: "timestamp"},
+}
+
+// listCursor marks the last row of a page: its sort value and row id
+type listCursor struct {
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func encodeCursor(cursor listCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor decodes a cursor whose value is compared as sqlType, so a
+// tampered value is a bad request rather than a failing query
+func decodeCursor(s string, sqlType string) (*listCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor listCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || !validCursorValue(cursor.Value, sqlType) {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// validCursorValue reports whether value, as written by cursorValue, casts
+// to sqlType
+func validCursorValue(value string, sqlType string) bool {
+	var err error
+	switch sqlType {
+	case "bigint":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "integer":
+		_, err = strconv.ParseInt(value, 10, 32)
+	case "real":
+		var f float64
+		f, err = strconv.ParseFloat(value, 32)
+		if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			return false
+		}
+	case "timestamp":
+		_, err = time.Parse(time.RFC3339Nano, value)
+	default:
+		return false
+	}
+	return err == nil
+}
+
+// NormalizeListQuery applies the default sort and clamps the page size
+func NormalizeListQuery(q *dto.ImageListQueryDto) {
+	if _, ok := listSorts[q.Sort]; !ok {
+		q.Sort = "liked_count"
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultListLimit
+	}
+	if q.Limit > MaxListLimit {
+		q.Limit = MaxListLimit
+	}
+}
+
+// ListImagesByCursor returns one page of public images using keyset
+// pagination, plus the cursor of the next page (empty on the last page)
+func ListImagesByCursor(ctx context.Context, q dto.ImageListQueryDto) ([]dto.FileResponse, string, error) {
+	return listImagesByCursor(ctx, q, false)
+}
+
+// ListOwnImagesByCursor lists every non-deleted image of q.User whatever its
+// moderation state or visibility, with the moderation status filled in
+func ListOwnImagesByCursor(ctx context.Context, q dto.ImageListQueryDto) ([]dto.FileResponse, string, error) {
+	return listImagesByCursor(ctx, q, true)
+}
+
+func listImagesByCursor(ctx context.Context, q dto.ImageListQueryDto, ownerView bool) ([]dto.FileResponse, string, error) {
+	NormalizeListQuery(&q)
+	sortKey := listSorts[q.Sort]
+
+	conditions := []string{publicImageCondition}
+	args := []any{}
+	columns := fileResponseColumns
+	var extra func(file *dto.FileResponse) []any
+
+	if ownerView {
+		conditions = []string{"i.deleted_at IS NULL"}
+		columns += ", " + moderationStatusColumn
+		extra = func(file *dto.FileResponse) []any {
+			return []any{&file.ModerationStatus}
+		}
+	}
+
+	if q.User != "" {
+		args = append(args, q.User)
+		conditions = append(conditions, fmt.Sprintf("i.uploaded_by = $%d", len(args)))
+	}
+
+	if q.Tag != "" {
+		args = append(args, q.Tag)
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM image_tags it JOIN tags t ON t.id = it.tag_id
+			WHERE it.image_id = i.image_id AND t.name = $%d)`, len(args)))
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeCursor(q.Cursor, sortKey.sqlType)
+		if err != nil {
+			return nil, "", err
+		}
+		args = append(args, cursor.Value, cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, i.id) < ($%d::%s, $%d)",
+			sortKey.column, len(args)-1, sortKey.sqlType, len(args)))
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	args = append(args, q.Limit+1)
+	query := fmt.Sprintf(`
+		SELECT %s, %s, i.id
+		FROM images i
+		WHERE %s
+		ORDER BY %s DESC, i.id DESC
+		LIMIT $%d
+	`, columns, sortKey.column, strings.Join(conditions, " AND "), sortKey.column, len(args))
+
+	return queryFileResponsePage(ctx, query, args, q.Limit, extra)
+}
+
+// queryFileResponsePage runs a listing query whose rows hold
+// fileResponseColumns, the columns of extra if any, then the sort value and
+// row id, and builds the cursor of the next page
+func queryFileResponsePage(ctx context.Context, query string, args []any, limit int, extra func(file *dto.FileResponse) []any) ([]dto.FileResponse, string, error) {
+	rows, err := postgresql.PostgresConnection.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to query database: %v", err)
+	}
+	defer rows.Close()
+
+	fileResponses := []dto.FileResponse{}
+	var last listCursor
+	more := false
+	for rows.Next() {
+		var file dto.FileResponse
+		var sortValue any
+		var id int64
+		fields := fileResponseFields(&file)
+		if extra != nil {
+			fields = append(fields, extra(&file)...)
+		}
+		if err := rows.Scan(append(fields, &sortValue, &id)...); err != nil {
+			return nil, "", fmt.Errorf("unable to scan row: %v", err)
+		}
+		if len(fileResponses) == limit {
+			more = true
+			break
+		}
+		fileResponses = append(fileResponses, file)
+		last = listCursor{Value: cursorValue(sortValue), ID: id}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("row iteration error: %v", err)
+	}
+
+	nextCursor := ""
+	if more {
+		nextCursor = encodeCursor(last)
+	}
+
+	validateThumbnails(ctx, fileResponses)
+	return fileResponses, nextCursor, nil
+}
+
+// cursorValue renders a scanned sort value so it can be cast back by Postgres
+func cursorValue(v any) string {
+	switch value := v.(type) {
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	case []byte:
+		return string(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// GetImageDetail returns the full metadata of an image regardless of its
+// moderation state; callers decide who may see unapproved images
+func GetImageDetail(ctx context.Context, imageID string) (*dto.ImageDetail, error) {
+	var detail dto.ImageDetail
+	fields := append(fileResponseFields(&detail.FileResponse),
+		&detail.UploadedBy, &detail.Width, &detail.Height, &detail.SizeBytes, &detail.CreatedAt, &detail.DeletedAt, &detail.Visibility, &detail.ModerationStatus)
+
+	err := postgresql.PostgresConnection.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT %s, COALESCE(i.uploaded_by, ''), i.width, i.height, i.size_bytes, i.created_at, i.deleted_at, i.visibility,
+			%s
+		FROM images i
+		WHERE i.image_id = $1
+	`, fileResponseColumns, moderationStatusColumn), imageID).Scan(fields...)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to query image %s: %v", imageID, err)
+	}
+
+	return &detail, nil
+}
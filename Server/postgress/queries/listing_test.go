@@ -0,0 +1,80 @@
+package postgressqueries
+
+import (
+	"MAIN_SERVER/components/Image/dto"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	// Values as scanned from the sort column of each type
+	values := []struct {
+		value   any
+		sqlType string
+	}{
+		{int64(42), "bigint"},
+		{time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC), "timestamp"},
+		{int64(-3), "integer"},
+		{0.0607927, "real"},
+		{int64(0), "bigint"},
+	}
+
+	for i, v := range values {
+		cursor := listCursor{Value: cursorValue(v.value), ID: int64(i)}
+		decoded, err := decodeCursor(encodeCursor(cursor), v.sqlType)
+		if err != nil {
+			t.Fatalf("decodeCursor(encodeCursor(%+v), %s) error = %v", cursor, v.sqlType, err)
+		}
+		if *decoded != cursor {
+			t.Errorf("decodeCursor(encodeCursor(%+v), %s) = %+v", cursor, v.sqlType, *decoded)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	invalid := map[string]struct {
+		cursor  string
+		sqlType string
+	}{
+		"not base64":          {"%%%", "bigint"},
+		"padded base64":       {base64.URLEncoding.EncodeToString([]byte(`{"v":"1","id":1}`)), "bigint"},
+		"not json":            {encode("v=1"), "bigint"},
+		"missing value":       {encode(`{"id":1}`), "bigint"},
+		"wrong id type":       {encode(`{"v":"1","id":"1"}`), "bigint"},
+		"text for bigint":     {encode(`{"v":"abc","id":1}`), "bigint"},
+		"float for bigint":    {encode(`{"v":"1.5","id":1}`), "bigint"},
+		"bigint for integer":  {encode(`{"v":"4294967296","id":1}`), "integer"},
+		"date only timestamp": {encode(`{"v":"2024-05-01","id":1}`), "timestamp"},
+		"count for timestamp": {encode(`{"v":"42","id":1}`), "timestamp"},
+		"nan rank":            {encode(`{"v":"NaN","id":1}`), "real"},
+		"infinite rank":       {encode(`{"v":"Infinity","id":1}`), "real"},
+		"sql in value":        {encode(`{"v":"1); DROP TABLE images;--","id":1}`), "real"},
+		"unknown type":        {encode(`{"v":"1","id":1}`), "text"},
+	}
+
+	for name, c := range invalid {
+		if _, err := decodeCursor(c.cursor, c.sqlType); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: decodeCursor(%q, %s) error = %v, want ErrInvalidCursor", name, c.cursor, c.sqlType, err)
+		}
+	}
+}
+
+func TestNormalizeListQuery(t *testing.T) {
+	q := dto.ImageListQueryDto{Sort: "bogus", Limit: MaxListLimit + 1}
+	NormalizeListQuery(&q)
+	if q.Sort != "liked_count" || q.Limit != MaxListLimit {
+		t.Errorf("NormalizeListQuery() = sort %q, limit %d, want liked_count, %d", q.Sort, q.Limit, MaxListLimit)
+	}
+
+	q = dto.ImageListQueryDto{Sort: "created_at"}
+	NormalizeListQuery(&q)
+	if q.Sort != "created_at" || q.Limit != DefaultListLimit {
+		t.Errorf("NormalizeListQuery() = sort %q, limit %d, want created_at, %d", q.Sort, q.Limit, DefaultListLimit)
+	}
+}
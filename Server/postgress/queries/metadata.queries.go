@@ -0,0 +1,58 @@
+package postgressqueries
+
+import (
+	"MAIN_SERVER/components/Image/dto"
+	postgresql "MAIN_SERVER/postgress"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// UpdateImageMetadata applies the non-nil fields of patch, tags must already
+// be normalized. Returns sql.ErrNoRows when the image does not exist or is
+// deleted.
+func UpdateImageMetadata(ctx context.Context, imageID string, patch dto.ImageMetadataPatchDto) error {
+	assignments := []string{}
+	args := []any{}
+
+	set := func(column string, value *string) {
+		if value == nil {
+			return
+		}
+		args = append(args, *value)
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	set("title", patch.Title)
+	set("description", patch.Description)
+	set("alt_text", patch.AltText)
+	set("visibility", patch.Visibility)
+
+	tx, err := postgresql.PostgresConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The no-op assignment keeps the statement valid when only tags change
+	assignments = append(assignments, "image_id = image_id")
+	args = append(args, imageID)
+	result, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE images
+		SET %s
+		WHERE image_id = $%d AND deleted_at IS NULL
+	`, strings.Join(assignments, ", "), len(args)), args...)
+	if err != nil {
+		return fmt.Errorf("unable to update image %s: %v", imageID, err)
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+
+	if patch.Tags != nil {
+		if err := setImageTags(ctx, tx, imageID, *patch.Tags); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
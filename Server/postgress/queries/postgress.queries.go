@@ -2,22 +2,26 @@ package postgressqueries
 
 import (
 	"MAIN_SERVER/components/Image/dto"
+	"MAIN_SERVER/config"
 	"MAIN_SERVER/gcs"
+	"MAIN_SERVER/logging"
+	"MAIN_SERVER/metrics"
 	postgresql "MAIN_SERVER/postgress"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"sort"
 	"sync"
 	"time"
 )
 
-func LikeImageCount(imageID string) (int, error) {
+func LikeImageCount(ctx context.Context, imageID string) (int, error) {
 
 	postgresql.PostgresDbConnect()
 	var likeCount int
 
-	err := postgresql.PostgresConnection.QueryRow("SELECT liked_count FROM images WHERE image_id = $1", imageID).Scan(&likeCount)
+	err := postgresql.PostgresConnection.QueryRowContext(ctx, "SELECT liked_count FROM images WHERE image_id = $1", imageID).Scan(&likeCount)
 	if err != nil {
 		return 0, err
 	}
@@ -61,6 +65,17 @@ func GetLikeCache() *LikeCache {
 		}
 		go likeCache.startBackgroundProcessor()
 		go likeCache.startCleanupProcessor()
+
+		metrics.RegisterGaugeFunc("like_cache_entries", "Images held in the like cache.", func() float64 {
+			likeCache.mutex.RLock()
+			defer likeCache.mutex.RUnlock()
+			return float64(len(likeCache.cache))
+		})
+		metrics.RegisterGaugeFunc("like_cache_pending_updates", "Images with likes not persisted yet.", func() float64 {
+			likeCache.mutex.RLock()
+			defer likeCache.mutex.RUnlock()
+			return float64(len(likeCache.pendingUpdates))
+		})
 	})
 	return likeCache
 }
@@ -81,8 +96,8 @@ func (c *LikeCache) startBackgroundProcessor() {
 }
 
 // updateDatabaseLikes updates the like count in the database
-func (c *LikeCache) updateDatabaseLikes(imageID string, likeCount int) error {
-	_, err := postgresql.PostgresConnection.Exec(`
+func (c *LikeCache) updateDatabaseLikes(ctx context.Context, imageID string, likeCount int) error {
+	_, err := postgresql.PostgresConnection.ExecContext(ctx, `
 		UPDATE images
 		SET liked_count = liked_count + $1
 		WHERE image_id = $2
@@ -91,9 +106,9 @@ func (c *LikeCache) updateDatabaseLikes(imageID string, likeCount int) error {
 	return err
 }
 
-func (c *LikeCache) getInitialCount(imageID string) (int, error) {
+func (c *LikeCache) getInitialCount(ctx context.Context, imageID string) (int, error) {
 	var count int
-	err := postgresql.PostgresConnection.QueryRow(`
+	err := postgresql.PostgresConnection.QueryRowContext(ctx, `
 		SELECT liked_count 
 		FROM images 
 		WHERE image_id = $1
@@ -102,7 +117,7 @@ func (c *LikeCache) getInitialCount(imageID string) (int, error) {
 	return count, err
 }
 
-func (c *LikeCache) LikeImage(imageID string) (int, error) {
+func (c *LikeCache) LikeImage(ctx context.Context, imageID string) (int, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
@@ -113,7 +128,7 @@ func (c *LikeCache) LikeImage(imageID string) (int, error) {
 			c.evictOldestEntries(100) // Remove 100 oldest entries
 		}
 
-		dbCount, err := c.getInitialCount(imageID)
+		dbCount, err := c.getInitialCount(ctx, imageID)
 		if err != nil {
 			return 0, err
 		}
@@ -172,8 +187,9 @@ func (c *LikeCache) processPendingLikes() {
 
 	if len(updates) > 0 {
 		for imageID, likeCount := range updates {
-			err := c.updateDatabaseLikes(imageID, likeCount)
+			err := c.updateDatabaseLikes(context.Background(), imageID, likeCount)
 			if err != nil {
+				metrics.LikeFlushErrors.Inc()
 				c.mutex.Lock()
 				if entry, exists := c.cache[imageID]; exists {
 					entry.cacheCount += likeCount
@@ -218,21 +234,55 @@ func (c *LikeCache) Stop() {
 	c.processPendingLikes() // Process any remaining updates
 }
 
-func InsertImageID(imageID string, userName string, fileName string, downloadURL string, thumbnailLink string) error {
+// InsertImageID records an uploaded image, its tags, usage and album in one
+// transaction
+func InsertImageID(ctx context.Context, imageID string, userName string, fileName string, downloadURL string, thumbnailLink string, width int, height int, sizeBytes int64, metadata dto.ImageMetadata) error {
 	postgresql.PostgresDbConnect()
 
-	_, err := postgresql.PostgresConnection.Exec(
-		"INSERT INTO images (image_id, created_at, liked_count,uploaded_by, file_name, download_url, thumbnail_link, is_approved, marked_for_review) VALUES ($1, CURRENT_TIMESTAMP, 0, $2, $3, $4, $5,0,0)",
+	tx, err := postgresql.PostgresConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO images (image_id, created_at, liked_count,uploaded_by, file_name, download_url, thumbnail_link, is_approved, marked_for_review, width, height, size_bytes, title, description, alt_text, visibility) VALUES ($1, CURRENT_TIMESTAMP, 0, $2, $3, $4, $5,0,0, $6, $7, $8, $9, $10, $11, $12)",
 		imageID,
 		userName,
 		fileName,
 		downloadURL,
 		thumbnailLink,
+		width,
+		height,
+		sizeBytes,
+		metadata.Title,
+		metadata.Description,
+		metadata.AltText,
+		metadata.Visibility,
 	)
 	if err != nil {
-		fmt.Println("Error inserting image ID:", err)
+		return fmt.Errorf("unable to insert image %s: %v", imageID, err)
+	}
+
+	if err := setImageTags(ctx, tx, imageID, metadata.Tags); err != nil {
+		return err
+	}
+
+	if err := adjustUsage(ctx, tx, userName, sizeBytes, 1); err != nil {
+		return err
+	}
+
+	if metadata.AlbumID != 0 {
+		if _, err := addAlbumImages(ctx, tx, metadata.AlbumID, userName, []string{imageID}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
 		return err
 	}
+
+	logging.FromContext(ctx).Debug("recorded image", "tags", len(metadata.Tags), "album_id", metadata.AlbumID)
 	return nil
 }
 
@@ -240,7 +290,6 @@ func InsertImageID(imageID string, userName string, fileName string, downloadURL
 type WorkerPool struct {
 	workerCount int
 	tasks       chan ValidationTask
-	results     chan ValidationResult
 	client      *http.Client
 }
 
@@ -248,6 +297,10 @@ type ValidationTask struct {
 	Index     int
 	ImageID   string
 	Thumbnail string
+
+	// results receives the outcome, one channel per listing so concurrent
+	// requests never read each other's results
+	results chan<- ValidationResult
 }
 
 type ValidationResult struct {
@@ -266,7 +319,7 @@ var (
 // GetWorkerPool returns the singleton worker pool instance
 func GetWorkerPool() *WorkerPool {
 	poolOnce.Do(func() {
-		globalWorkerPool = NewWorkerPool(5) // Adjust worker count based on your needs
+		globalWorkerPool = NewWorkerPool(config.Get().Workers.Thumbnails)
 	})
 	return globalWorkerPool
 }
@@ -275,7 +328,6 @@ func NewWorkerPool(workerCount int) *WorkerPool {
 	wp := &WorkerPool{
 		workerCount: workerCount,
 		tasks:       make(chan ValidationTask, workerCount*2),
-		results:     make(chan ValidationResult, workerCount*2),
 		client: &http.Client{
 			Timeout: 5 * time.Second,
 		},
@@ -300,21 +352,29 @@ func (wp *WorkerPool) worker() {
 		}
 
 		// Check if thumbnail is accessible
+		outcome := "valid"
 		resp, err := wp.client.Head(task.Thumbnail)
+		if err == nil {
+			resp.Body.Close()
+		}
 		if err != nil || resp.StatusCode != http.StatusOK {
 			result.IsValid = false
 			// Try to refresh the thumbnail
 			newLink, refreshErr := RefreshThumbnailLink(task.ImageID)
 			if refreshErr == nil {
 				result.NewLink = newLink
+				outcome = "refreshed"
+			} else {
+				outcome = "failed"
 			}
 		}
+		metrics.ThumbnailValidations.WithLabelValues(outcome).Inc()
 
-		wp.results <- result
+		task.results <- result
 	}
 }
 
-func ListImages(pageNumber int, pageSize int, orderBy string) ([]dto.FileResponse, int, error) {
+func ListImages(ctx context.Context, pageNumber int, pageSize int, orderBy string) ([]dto.FileResponse, int, error) {
 	validOrderBys := map[string]bool{
 		"liked_count": true,
 		"created_at":  true,
@@ -324,55 +384,87 @@ func ListImages(pageNumber int, pageSize int, orderBy string) ([]dto.FileRespons
 		orderBy = "liked_count"
 	}
 
+	// Clamp the page like NormalizeListQuery, a zero page size would divide
+	// by zero below
+	if pageSize <= 0 {
+		pageSize = DefaultListLimit
+	}
+	pageSize = min(pageSize, MaxListLimit)
+	pageNumber = max(pageNumber, 0)
 	offset := pageNumber * pageSize
 
+	// The page and the total count must filter the same rows
+	const where = "WHERE " + publicImageCondition
+
 	query := fmt.Sprintf(`
-        SELECT image_id, file_name, thumbnail_link, liked_count, download_url
-        FROM images
-		WHERE is_approved = 1
-        ORDER BY %s DESC
+        SELECT %s
+        FROM images i
+		%s
+        ORDER BY i.%s DESC
         LIMIT $1 OFFSET $2
-    `, orderBy)
+    `, fileResponseColumns, where, orderBy)
 
-	rows, err := postgresql.PostgresConnection.Query(query, pageSize, offset)
+	rows, err := postgresql.PostgresConnection.QueryContext(ctx, query, pageSize, offset)
 	if err != nil {
 		return nil, 0, fmt.Errorf("unable to query database: %v", err)
 	}
 	defer rows.Close()
 
 	var fileResponses []dto.FileResponse
-	wp := GetWorkerPool()
-	taskCount := 0
-
-	// First pass: collect all responses and submit validation tasks
-	for i := 0; rows.Next(); i++ {
+	for rows.Next() {
 		var file dto.FileResponse
-		if err := rows.Scan(&file.ID, &file.Name, &file.Thumbnail, &file.LikedCount, &file.DownloadURL); err != nil {
+		if err := rows.Scan(fileResponseFields(&file)...); err != nil {
 			return nil, 0, fmt.Errorf("unable to scan row: %v", err)
 		}
 		fileResponses = append(fileResponses, file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("row iteration error: %v", err)
+	}
 
-		// Submit validation task
+	validateThumbnails(ctx, fileResponses)
+
+	// Get total count
+	var totalCount int
+	countQuery := `SELECT COUNT(*) FROM images i ` + where
+	err = postgresql.PostgresConnection.QueryRowContext(ctx, countQuery).Scan(&totalCount)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to get total count: %v", err)
+	}
+
+	totalPages := (totalCount + pageSize - 1) / pageSize
+	return fileResponses, totalPages, nil
+}
+
+// validateThumbnails checks every thumbnail link through the worker pool and
+// replaces expired links in place, persisting the fresh ones in the background
+func validateThumbnails(ctx context.Context, fileResponses []dto.FileResponse) {
+	if len(fileResponses) == 0 {
+		return
+	}
+
+	wp := GetWorkerPool()
+	results := make(chan ValidationResult, len(fileResponses))
+
+	// Submit validation tasks
+	for i, file := range fileResponses {
 		wp.tasks <- ValidationTask{
 			Index:     i,
 			ImageID:   file.ID,
 			Thumbnail: file.Thumbnail,
+			results:   results,
 		}
-		taskCount++
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("row iteration error: %v", err)
 	}
 
 	// Collect validation results
-	for i := 0; i < taskCount; i++ {
-		result := <-wp.results
+	for range fileResponses {
+		result := <-results
 		if !result.IsValid && result.NewLink != "" {
-			// Update the database in background
+			// Update the database in background, past the end of the request
 			go func(imageID, newLink string) {
-				if err := updateThumbnailInDB(imageID, newLink); err != nil {
-					log.Printf("Error updating thumbnail for image %s: %v", imageID, err)
+				if err := updateThumbnailInDB(context.WithoutCancel(ctx), imageID, newLink); err != nil {
+					slog.Warn("updating thumbnail failed", "image_id", imageID, "err", err)
 				}
 			}(result.ImageID, result.NewLink)
 
@@ -380,28 +472,17 @@ func ListImages(pageNumber int, pageSize int, orderBy string) ([]dto.FileRespons
 			fileResponses[result.Index].Thumbnail = result.NewLink
 		}
 	}
-
-	// Get total count
-	var totalCount int
-	countQuery := `SELECT COUNT(*) FROM images`
-	err = postgresql.PostgresConnection.QueryRow(countQuery).Scan(&totalCount)
-	if err != nil {
-		return nil, 0, fmt.Errorf("unable to get total count: %v", err)
-	}
-
-	totalPages := (totalCount + pageSize - 1) / pageSize
-	return fileResponses, totalPages, nil
 }
 
 // updateThumbnailInDB updates the thumbnail link in the database
-func updateThumbnailInDB(imageID, newThumbnail string) error {
+func updateThumbnailInDB(ctx context.Context, imageID, newThumbnail string) error {
 	query := `
         UPDATE images 
         SET thumbnail_link = $1 
         WHERE image_id = $2
     `
 
-	_, err := postgresql.PostgresConnection.Exec(query, newThumbnail, imageID)
+	_, err := postgresql.PostgresConnection.ExecContext(ctx, query, newThumbnail, imageID)
 	return err
 }
 
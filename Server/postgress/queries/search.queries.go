@@ -0,0 +1,70 @@
+package postgressqueries
+
+import (
+	"MAIN_SERVER/components/Image/dto"
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxSearchTerms bounds the size of the generated tsquery
+const maxSearchTerms = 8
+
+// BuildPrefixQuery turns free text into a tsquery matching every word as a
+// prefix, e.g. "red ca" becomes "red:* & ca:*". Returns an empty string when
+// the text has no searchable word.
+func BuildPrefixQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if len(terms) == maxSearchTerms {
+			break
+		}
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
+// SearchImages ranks public images against a prefix tsquery built by
+// BuildPrefixQuery, paginated with the same cursors as ListImagesByCursor
+func SearchImages(ctx context.Context, tsQuery string, cursor string, limit int) ([]dto.FileResponse, string, error) {
+	q := dto.ImageListQueryDto{Limit: limit}
+	NormalizeListQuery(&q)
+
+	const rank = "ts_rank_cd(i.search_vector, q.query)"
+	conditions := []string{publicImageCondition, "i.search_vector @@ q.query"}
+	args := []any{tsQuery}
+
+	if cursor != "" {
+		decoded, err := decodeCursor(cursor, "real")
+		if err != nil {
+			return nil, "", err
+		}
+		args = append(args, decoded.Value, decoded.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, i.id) < ($%d::real, $%d)", rank, len(args)-1, len(args)))
+	}
+
+	// The text is HTML escaped before it is highlighted, so the <mark> tags
+	// are the only markup in the headline
+	args = append(args, q.Limit+1)
+	query := fmt.Sprintf(`
+		SELECT %s,
+			ts_headline('simple', replace(replace(replace(replace(
+				concat_ws(' ', NULLIF(i.title, ''), NULLIF(i.description, ''), i.file_name),
+				'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), q.query,
+				'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5'),
+			%s, i.id
+		FROM images i, to_tsquery('simple', $1) AS q(query)
+		WHERE %s
+		ORDER BY %s DESC, i.id DESC
+		LIMIT $%d
+	`, fileResponseColumns, rank, strings.Join(conditions, " AND "), rank, len(args))
+
+	return queryFileResponsePage(ctx, query, args, q.Limit, func(file *dto.FileResponse) []any {
+		return []any{&file.Highlight}
+	})
+}
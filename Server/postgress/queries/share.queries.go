@@ -0,0 +1,68 @@
+package postgressqueries
+
+import (
+	sharedto "MAIN_SERVER/components/Share/dto"
+	postgresql "MAIN_SERVER/postgress"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+func CreateShareLink(ctx context.Context, link sharedto.ShareLink) error {
+	_, err := postgresql.PostgresConnection.ExecContext(ctx, `
+		INSERT INTO share_links (id, image_id, created_by, expires_at, password_hash, max_views)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, 0))
+	`, link.ID, link.ImageID, link.CreatedBy, link.ExpiresAt, link.PasswordHash, link.MaxViews)
+	if err != nil {
+		return fmt.Errorf("unable to create share link for image %s: %v", link.ImageID, err)
+	}
+	return nil
+}
+
+// GetShareLink returns sql.ErrNoRows for unknown and revoked links
+func GetShareLink(ctx context.Context, id string) (*sharedto.ShareLink, error) {
+	var link sharedto.ShareLink
+	err := postgresql.PostgresConnection.QueryRowContext(ctx, `
+		SELECT id, image_id, created_by, expires_at, COALESCE(password_hash, ''), COALESCE(max_views, 0), view_count
+		FROM share_links
+		WHERE id = $1 AND revoked_at IS NULL
+	`, id).Scan(&link.ID, &link.ImageID, &link.CreatedBy, &link.ExpiresAt, &link.PasswordHash, &link.MaxViews, &link.ViewCount)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to query share link: %v", err)
+	}
+	return &link, nil
+}
+
+// RevokeShareLink returns sql.ErrNoRows when the user created no such live
+// link
+func RevokeShareLink(ctx context.Context, id string, userName string) error {
+	result, err := postgresql.PostgresConnection.ExecContext(ctx, `
+		UPDATE share_links
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND created_by = $2 AND revoked_at IS NULL
+	`, id, userName)
+	if err != nil {
+		return fmt.Errorf("unable to revoke share link: %v", err)
+	}
+	return requireAffected(result)
+}
+
+// ConsumeShareView counts one view of a link, returns sql.ErrNoRows when the
+// link has expired, was revoked or has no views left
+func ConsumeShareView(ctx context.Context, id string) error {
+	result, err := postgresql.PostgresConnection.ExecContext(ctx, `
+		UPDATE share_links
+		SET view_count = view_count + 1
+		WHERE id = $1
+		  AND revoked_at IS NULL
+		  AND expires_at > CURRENT_TIMESTAMP
+		  AND (max_views IS NULL OR view_count < max_views)
+	`, id)
+	if err != nil {
+		return fmt.Errorf("unable to update share link: %v", err)
+	}
+	return requireAffected(result)
+}
@@ -0,0 +1,96 @@
+package postgressqueries
+
+import (
+	tagdto "MAIN_SERVER/components/Tag/dto"
+	postgresql "MAIN_SERVER/postgress"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// setImageTags replaces the tags of an image, tags must already be normalized
+func setImageTags(ctx context.Context, db execer, imageID string, tags []string) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM image_tags WHERE image_id = $1", imageID); err != nil {
+		return fmt.Errorf("unable to clear tags of image %s: %v", imageID, err)
+	}
+
+	// Keep the denormalized copy used by the search vector in sync
+	_, err := db.ExecContext(ctx, "UPDATE images SET tag_names = $1 WHERE image_id = $2", strings.Join(tags, " "), imageID)
+	if err != nil {
+		return fmt.Errorf("unable to update tags of image %s: %v", imageID, err)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO tags (name)
+		SELECT unnest($1::text[])
+		ON CONFLICT (name) DO NOTHING
+	`, pq.Array(tags))
+	if err != nil {
+		return fmt.Errorf("unable to create tags: %v", err)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO image_tags (image_id, tag_id)
+		SELECT $1, id FROM tags WHERE name = ANY($2::text[])
+	`, imageID, pq.Array(tags))
+	if err != nil {
+		return fmt.Errorf("unable to tag image %s: %v", imageID, err)
+	}
+	return nil
+}
+
+// SetImageTags replaces the tags of an image in one transaction
+func SetImageTags(ctx context.Context, imageID string, tags []string) error {
+	tx, err := postgresql.PostgresConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setImageTags(ctx, tx, imageID, tags); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AutocompleteTags returns the most used tags starting with prefix, counting
+// only images visible in listings
+func AutocompleteTags(ctx context.Context, prefix string, limit int) ([]tagdto.TagResponse, error) {
+	rows, err := postgresql.PostgresConnection.QueryContext(ctx, `
+		SELECT t.name, COUNT(i.image_id)
+		FROM tags t
+		LEFT JOIN image_tags it ON it.tag_id = t.id
+		LEFT JOIN images i ON i.image_id = it.image_id AND `+publicImageCondition+`
+		WHERE t.name LIKE $1 || '%'
+		GROUP BY t.name
+		HAVING COUNT(i.image_id) > 0
+		ORDER BY COUNT(i.image_id) DESC, t.name
+		LIMIT $2
+	`, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query tags: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []tagdto.TagResponse{}
+	for rows.Next() {
+		var tag tagdto.TagResponse
+		if err := rows.Scan(&tag.Name, &tag.ImageCount); err != nil {
+			return nil, fmt.Errorf("unable to scan row: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
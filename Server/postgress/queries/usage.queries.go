@@ -0,0 +1,53 @@
+package postgressqueries
+
+import (
+	postgresql "MAIN_SERVER/postgress"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// UserUsage is the stored usage of a user along with their quota settings
+type UserUsage struct {
+	BytesStored int64
+	FileCount   int
+	Tier        string
+	QuotaBytes  sql.NullInt64 // Per-user override of the tier limit
+	QuotaFiles  sql.NullInt64
+}
+
+// adjustUsage adds bytes and files, possibly negative, to a user's usage.
+// Images without an uploader belong to no one and are not counted.
+func adjustUsage(ctx context.Context, db execer, userName string, bytes int64, files int) error {
+	if userName == "" {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO user_usage (username, bytes_stored, file_count)
+		VALUES ($1, GREATEST($2, 0), GREATEST($3, 0))
+		ON CONFLICT (username) DO UPDATE
+		SET bytes_stored = GREATEST(user_usage.bytes_stored + $2, 0),
+			file_count   = GREATEST(user_usage.file_count + $3, 0),
+			updated_at   = CURRENT_TIMESTAMP
+	`, userName, bytes, files)
+	if err != nil {
+		return fmt.Errorf("unable to update usage of %s: %v", userName, err)
+	}
+	return nil
+}
+
+// GetUserUsage returns zero usage on the free tier for unknown users
+func GetUserUsage(ctx context.Context, userName string) (*UserUsage, error) {
+	usage := UserUsage{Tier: "free"}
+	err := postgresql.PostgresConnection.QueryRowContext(ctx, `
+		SELECT COALESCE(u.bytes_stored, 0), COALESCE(u.file_count, 0),
+			COALESCE(p.tier, 'free'), p.quota_bytes, p.quota_files
+		FROM (SELECT $1::text AS username) n
+		LEFT JOIN user_usage u ON u.username = n.username
+		LEFT JOIN user_profiles p ON p.username = n.username
+	`, userName).Scan(&usage.BytesStored, &usage.FileCount, &usage.Tier, &usage.QuotaBytes, &usage.QuotaFiles)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query usage of %s: %v", userName, err)
+	}
+	return &usage, nil
+}
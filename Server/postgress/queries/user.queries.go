@@ -0,0 +1,64 @@
+package postgressqueries
+
+import (
+	userdto "MAIN_SERVER/components/User/dto"
+	postgresql "MAIN_SERVER/postgress"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// GetUserProfile aggregates a user's uploads. The upload count only covers
+// public approved images unless ownerView is set. Returns sql.ErrNoRows for
+// users that never uploaded nor created a profile.
+func GetUserProfile(ctx context.Context, userName string, ownerView bool) (*userdto.UserProfileResponse, error) {
+	profile := userdto.UserProfileResponse{UserName: userName}
+	var publicCount, totalCount int
+	var joinedAt sql.NullTime
+
+	err := postgresql.PostgresConnection.QueryRowContext(ctx, `
+		WITH stats AS (
+			SELECT
+				COUNT(*) FILTER (WHERE i.is_approved = 1 AND i.visibility = 'public') AS public_count,
+				COUNT(*) AS total_count,
+				COALESCE(SUM(i.liked_count), 0) AS likes,
+				MIN(i.created_at) AS first_upload
+			FROM images i
+			WHERE i.uploaded_by = $1 AND i.deleted_at IS NULL
+		)
+		SELECT s.public_count, s.total_count, s.likes, LEAST(s.first_upload, p.created_at),
+			COALESCE(p.avatar_image_id, ''), COALESCE(a.thumbnail_link, '')
+		FROM stats s
+		LEFT JOIN user_profiles p ON p.username = $1
+		LEFT JOIN images a ON a.image_id = p.avatar_image_id
+			AND a.is_approved = 1 AND a.deleted_at IS NULL AND a.visibility <> 'private'
+	`, userName).Scan(&publicCount, &totalCount, &profile.TotalLikesReceived, &joinedAt,
+		&profile.AvatarImageID, &profile.AvatarThumbnail)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query user %s: %v", userName, err)
+	}
+	if !joinedAt.Valid {
+		return nil, sql.ErrNoRows
+	}
+
+	profile.JoinedAt = joinedAt.Time
+	profile.UploadCount = publicCount
+	if ownerView {
+		profile.UploadCount = totalCount
+	}
+	return &profile, nil
+}
+
+// SetUserAvatar sets or, with an empty imageID, clears a user's avatar
+func SetUserAvatar(ctx context.Context, userName string, imageID string) error {
+	_, err := postgresql.PostgresConnection.ExecContext(ctx, `
+		INSERT INTO user_profiles (username, avatar_image_id)
+		VALUES ($1, NULLIF($2, ''))
+		ON CONFLICT (username) DO UPDATE
+		SET avatar_image_id = EXCLUDED.avatar_image_id, updated_at = CURRENT_TIMESTAMP
+	`, userName, imageID)
+	if err != nil {
+		return fmt.Errorf("unable to update user %s: %v", userName, err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package quota
+
+import (
+	"MAIN_SERVER/config"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Limits caps the storage of one user
+type Limits struct {
+	MaxBytes int64 `json:"max_bytes"`
+	MaxFiles int   `json:"max_files"`
+}
+
+// ExceededError is returned when an upload would not fit in the quota
+type ExceededError struct {
+	Usage  Usage
+	Bytes  int64
+	Files  int
+	Reason string
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("upload of %d files (%d bytes) exceeds the %s quota", e.Files, e.Bytes, e.Reason)
+}
+
+// Usage is what GET /me/usage reports
+type Usage struct {
+	UserName     string `json:"username"`
+	Tier         string `json:"tier"`
+	BytesStored  int64  `json:"bytes_stored"`
+	FileCount    int    `json:"file_count"`
+	PendingBytes int64  `json:"pending_bytes"` // Accepted uploads still being processed
+	PendingFiles int    `json:"pending_files"`
+	Limits
+}
+
+// pending tracks uploads accepted by this instance that are not in the
+// database yet, so a burst of requests cannot overshoot the quota. It is per
+// instance: behind a load balancer each server only sees its own pending
+// uploads, so concurrent bursts spread over N servers may overshoot by up to
+// N-1 batches until they are stored. Hashing uploads by user
+// (balancing.hash_key: user) keeps a user's uploads on one server.
+var (
+	pendingBytes = make(map[string]int64)
+	pendingFiles = make(map[string]int)
+	mutex        sync.Mutex
+)
+
+// getUserUsage reads the stored usage, tests replace it
+var getUserUsage = postgressqueries.GetUserUsage
+
+// TierLimits returns the limits of a tier, unknown tiers get the free limits
+func TierLimits(tier string) Limits {
+	quota := config.Get().Limits.FreeQuota
+	if tier == "pro" {
+		quota = config.Get().Limits.ProQuota
+	}
+	return Limits{MaxBytes: quota.MaxBytes, MaxFiles: quota.MaxFiles}
+}
+
+// GetUsage returns the stored and pending usage of a user with their limits
+func GetUsage(ctx context.Context, userName string) (*Usage, error) {
+	stored, err := getUserUsage(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := TierLimits(stored.Tier)
+	if stored.QuotaBytes.Valid {
+		limits.MaxBytes = stored.QuotaBytes.Int64
+	}
+	if stored.QuotaFiles.Valid {
+		limits.MaxFiles = int(stored.QuotaFiles.Int64)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return &Usage{
+		UserName:     userName,
+		Tier:         stored.Tier,
+		BytesStored:  stored.BytesStored,
+		FileCount:    stored.FileCount,
+		PendingBytes: pendingBytes[userName],
+		PendingFiles: pendingFiles[userName],
+		Limits:       limits,
+	}, nil
+}
+
+// Reserve checks that bytes and files fit in the user's quota and holds them
+// until Release is called once per file
+func Reserve(ctx context.Context, userName string, bytes int64, files int) error {
+	usage, err := GetUsage(ctx, userName)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// Re-read pending under the lock, it may have grown since GetUsage
+	totalBytes := usage.BytesStored + pendingBytes[userName] + bytes
+	totalFiles := usage.FileCount + pendingFiles[userName] + files
+
+	switch {
+	case usage.MaxBytes > 0 && totalBytes > usage.MaxBytes:
+		return &ExceededError{Usage: *usage, Bytes: bytes, Files: files, Reason: "storage"}
+	case usage.MaxFiles > 0 && totalFiles > usage.MaxFiles:
+		return &ExceededError{Usage: *usage, Bytes: bytes, Files: files, Reason: "file count"}
+	}
+
+	pendingBytes[userName] += bytes
+	pendingFiles[userName] += files
+	return nil
+}
+
+// Release drops a reservation once the upload is stored or has failed
+func Release(userName string, bytes int64, files int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	pendingBytes[userName] -= bytes
+	pendingFiles[userName] -= files
+	if pendingFiles[userName] <= 0 {
+		delete(pendingBytes, userName)
+		delete(pendingFiles, userName)
+	}
+}
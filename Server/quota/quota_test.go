@@ -0,0 +1,108 @@
+package quota
+
+import (
+	"MAIN_SERVER/config"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("DATABASE_URL", "postgres://test")
+	os.Setenv("FOLDER_ID", "test")
+	os.Setenv("QUOTA_PRO_MAX_FILES", "7")
+	if _, err := config.Load(""); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// storedUsage makes GetUsage see bytes and files stored against a quota of
+// 1000 bytes and 10 files
+func storedUsage(bytes int64, files int) {
+	getUserUsage = func(context.Context, string) (*postgressqueries.UserUsage, error) {
+		return &postgressqueries.UserUsage{
+			BytesStored: bytes,
+			FileCount:   files,
+			Tier:        "free",
+			QuotaBytes:  sql.NullInt64{Int64: 1000, Valid: true},
+			QuotaFiles:  sql.NullInt64{Int64: 10, Valid: true},
+		}, nil
+	}
+}
+
+func TestReserveWithinQuota(t *testing.T) {
+	storedUsage(900, 9)
+
+	if err := Reserve(context.Background(), "filler", 100, 1); err != nil {
+		t.Fatalf("Reserve() of the last 100 bytes error = %v", err)
+	}
+	Release("filler", 100, 1)
+}
+
+func TestReserveOverQuota(t *testing.T) {
+	tests := []struct {
+		user        string
+		storedBytes int64
+		storedFiles int
+		bytes       int64
+		files       int
+		wantReason  string
+	}{
+		{user: "big file", storedBytes: 950, bytes: 100, files: 1, wantReason: "storage"},
+		{user: "many files", storedFiles: 10, bytes: 1, files: 1, wantReason: "file count"},
+	}
+
+	for _, test := range tests {
+		storedUsage(test.storedBytes, test.storedFiles)
+
+		var exceeded *ExceededError
+		err := Reserve(context.Background(), test.user, test.bytes, test.files)
+		if !errors.As(err, &exceeded) {
+			t.Fatalf("%s: Reserve() error = %v, want ExceededError", test.user, err)
+		}
+		if exceeded.Reason != test.wantReason {
+			t.Errorf("%s: Reason = %q, want %q", test.user, exceeded.Reason, test.wantReason)
+		}
+	}
+}
+
+func TestReserveCountsPendingUploads(t *testing.T) {
+	storedUsage(0, 0)
+	ctx := context.Background()
+
+	for range 2 {
+		if err := Reserve(ctx, "releaser", 400, 4); err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+	}
+	if err := Reserve(ctx, "releaser", 400, 4); err == nil {
+		t.Fatal("third reservation fit in the quota")
+	}
+
+	Release("releaser", 400, 4)
+	usage, err := GetUsage(ctx, "releaser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.PendingBytes != 400 || usage.PendingFiles != 4 {
+		t.Errorf("pending = %d bytes, %d files, want 400, 4", usage.PendingBytes, usage.PendingFiles)
+	}
+
+	Release("releaser", 400, 4)
+	if _, held := pendingFiles["releaser"]; held {
+		t.Error("released user is still tracked")
+	}
+}
+
+func TestTierLimits(t *testing.T) {
+	if got := TierLimits("pro"); got.MaxFiles != 7 || got.MaxBytes != 50<<30 {
+		t.Errorf("TierLimits(pro) = %+v, want the pro bytes and 7 files", got)
+	}
+	if got := TierLimits("unknown"); got != TierLimits("free") {
+		t.Errorf("TierLimits(unknown) = %+v, want the free limits", got)
+	}
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket refilled continuously at the limiter rate
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// Limiter is an in-memory token bucket limiter keyed by an arbitrary string
+// such as a user name or client IP
+type Limiter struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	buckets map[string]*bucket
+	mutex   sync.Mutex
+	done    chan bool
+}
+
+// NewLimiter allows limit events per period with bursts of up to burst
+// events. Idle buckets are dropped in the background.
+func NewLimiter(limit int, period time.Duration, burst int) *Limiter {
+	l := &Limiter{
+		rate:    float64(limit) / period.Seconds(),
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		done:    make(chan bool),
+	}
+
+	go l.startCleanupProcessor()
+	return l
+}
+
+// Result is the state of the buckets after Take
+type Result struct {
+	Allowed    bool
+	Remaining  int           // Whole tokens left in the emptiest bucket
+	RetryAfter time.Duration // Until every bucket has a token, when not allowed
+	Reset      time.Duration // Until every bucket is full again
+}
+
+// Take takes one token from the bucket of each key. When any of them is
+// empty no token is taken at all, so a denied request costs nothing.
+func (l *Limiter) Take(keys ...string) Result {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	buckets := make([]*bucket, len(keys))
+	result := Result{Allowed: true, Remaining: int(l.burst)}
+
+	for i, key := range keys {
+		b, exists := l.buckets[key]
+		if !exists {
+			b = &bucket{tokens: l.burst, lastSeen: now}
+			l.buckets[key] = b
+		}
+
+		// Refill for the time elapsed since the last call
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.lastSeen).Seconds()*l.rate)
+		b.lastSeen = now
+		buckets[i] = b
+
+		if b.tokens < 1 {
+			result.Allowed, result.Remaining = false, 0
+			result.RetryAfter = max(result.RetryAfter, l.duration(1-b.tokens))
+		}
+	}
+
+	for _, b := range buckets {
+		if result.Allowed {
+			b.tokens--
+			result.Remaining = min(result.Remaining, int(b.tokens))
+		}
+		result.Reset = max(result.Reset, l.duration(l.burst-b.tokens))
+	}
+	return result
+}
+
+// duration returns how long it takes to refill tokens
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// SetRate changes the rate and burst, existing buckets keep their tokens up
+// to the new burst
+func (l *Limiter) SetRate(limit int, period time.Duration, burst int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.rate = float64(limit) / period.Seconds()
+	l.burst = float64(burst)
+}
+
+// Burst returns the bucket capacity
+func (l *Limiter) Burst() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return int(l.burst)
+}
+
+func (l *Limiter) startCleanupProcessor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.cleanupFullBuckets()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// cleanupFullBuckets drops buckets that would be full by now, they behave
+// exactly like a missing bucket
+func (l *Limiter) cleanupFullBuckets() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.lastSeen).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *Limiter) Stop() {
+	l.done <- true
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// within reports whether got is want give or take the time the test took
+func within(got, want time.Duration) bool {
+	return got <= want && got > want-time.Second
+}
+
+func TestTake(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit, burst  int
+		takes         int
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+		wantReset     time.Duration
+	}{
+		{name: "first request", limit: 5, burst: 5, takes: 1, wantAllowed: true, wantRemaining: 4, wantReset: 12 * time.Minute},
+		{name: "last token", limit: 5, burst: 5, takes: 5, wantAllowed: true, wantRemaining: 0, wantReset: time.Hour},
+		{name: "over the limit", limit: 5, burst: 5, takes: 6, wantRetry: 12 * time.Minute, wantReset: time.Hour},
+		{name: "burst above limit", limit: 1, burst: 3, takes: 3, wantAllowed: true, wantRemaining: 0, wantReset: 3 * time.Hour},
+		{name: "burst exhausted", limit: 1, burst: 3, takes: 4, wantRetry: time.Hour, wantReset: 3 * time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := NewLimiter(test.limit, time.Hour, test.burst)
+			defer limiter.Stop()
+
+			var result Result
+			for range test.takes {
+				result = limiter.Take("key")
+			}
+
+			if result.Allowed != test.wantAllowed || result.Remaining != test.wantRemaining {
+				t.Errorf("Take() = %v, %d, want %v, %d", result.Allowed, result.Remaining, test.wantAllowed, test.wantRemaining)
+			}
+			if test.wantRetry != 0 && !within(result.RetryAfter, test.wantRetry) || test.wantRetry == 0 && result.RetryAfter != 0 {
+				t.Errorf("RetryAfter = %v, want %v", result.RetryAfter, test.wantRetry)
+			}
+			if !within(result.Reset, test.wantReset) {
+				t.Errorf("Reset = %v, want %v", result.Reset, test.wantReset)
+			}
+		})
+	}
+}
+
+func TestTakeKeysAreIndependent(t *testing.T) {
+	limiter := NewLimiter(1, time.Hour, 1)
+	defer limiter.Stop()
+
+	if !limiter.Take("a").Allowed {
+		t.Fatal("first request of a was throttled")
+	}
+	if limiter.Take("a").Allowed {
+		t.Error("second request of a was allowed")
+	}
+	if !limiter.Take("b").Allowed {
+		t.Error("b was throttled by a's bucket")
+	}
+}
+
+func TestTakeSeveralKeys(t *testing.T) {
+	limiter := NewLimiter(1, time.Hour, 2)
+	defer limiter.Stop()
+
+	limiter.Take("ip")
+	limiter.Take("ip")
+	if result := limiter.Take("ip", "user"); result.Allowed || result.Remaining != 0 {
+		t.Fatalf("Take() with an empty bucket = %+v, want denied", result)
+	}
+
+	// The denied request must not have spent the user's tokens
+	if result := limiter.Take("user", "other ip"); !result.Allowed || result.Remaining != 1 {
+		t.Errorf("Take() = %+v, want allowed with 1 remaining", result)
+	}
+	if result := limiter.Take("user", "other ip"); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Take() = %+v, want allowed with 0 remaining", result)
+	}
+}
+
+func TestSetRate(t *testing.T) {
+	limiter := NewLimiter(1, time.Hour, 1)
+	defer limiter.Stop()
+
+	limiter.Take("key")
+	limiter.SetRate(1, time.Millisecond, 2)
+	time.Sleep(5 * time.Millisecond)
+
+	if !limiter.Take("key").Allowed {
+		t.Error("bucket did not refill at the new rate")
+	}
+	if limiter.Burst() != 2 {
+		t.Errorf("Burst() = %d, want 2", limiter.Burst())
+	}
+}
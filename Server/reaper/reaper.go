@@ -0,0 +1,92 @@
+package reaper
+
+import (
+	"MAIN_SERVER/config"
+	"MAIN_SERVER/gcs/queries"
+	postgressqueries "MAIN_SERVER/postgress/queries"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// Reaper purges soft-deleted images from storage and the database once their
+// retention window has passed
+type Reaper struct {
+	interval  time.Duration
+	batchSize int
+	done      chan bool
+}
+
+var (
+	reaper *Reaper
+	once   sync.Once
+	tracer = otel.Tracer("MAIN_SERVER/reaper")
+)
+
+// Retention returns the configured soft-delete retention window
+func Retention() time.Duration {
+	return time.Duration(config.Get().Limits.DeleteRetentionHours) * time.Hour
+}
+
+// GetReaper returns the singleton reaper, starting it on first use
+func GetReaper() *Reaper {
+	once.Do(func() {
+		reaper = &Reaper{
+			interval:  10 * time.Minute,
+			batchSize: 100,
+			done:      make(chan bool),
+		}
+		go reaper.start()
+	})
+	return reaper
+}
+
+func (r *Reaper) start() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.purgeExpired()
+	for {
+		select {
+		case <-ticker.C:
+			r.purgeExpired()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// purgeExpired deletes the stored object first so a failed storage call keeps
+// the row around for the next run
+func (r *Reaper) purgeExpired() {
+	logger := slog.With("component", "reaper")
+
+	// Each run is a trace of its own
+	ctx, span := tracer.Start(context.Background(), "reaper purge")
+	defer span.End()
+
+	imageIDs, err := postgressqueries.ListReapableImages(ctx, Retention(), r.batchSize)
+	if err != nil {
+		logger.Error("listing reapable images failed", "err", err)
+		return
+	}
+
+	for _, imageID := range imageIDs {
+		if err := queries.DeleteImageFromDrive(ctx, imageID); err != nil {
+			logger.Error("deleting stored image failed", "image_id", imageID, "err", err)
+			continue
+		}
+		if err := postgressqueries.PurgeImage(ctx, imageID); err != nil {
+			logger.Error("purging image failed", "image_id", imageID, "err", err)
+			continue
+		}
+		logger.Info("purged image", "image_id", imageID)
+	}
+}
+
+func (r *Reaper) Stop() {
+	r.done <- true
+}
@@ -1,46 +1,118 @@
 package worker
 
 import (
+	"MAIN_SERVER/components/Image/dto"
+	"MAIN_SERVER/config"
 	"MAIN_SERVER/gcs/queries"
-	"fmt"
+	"MAIN_SERVER/logging"
+	"MAIN_SERVER/metrics"
+	"context"
 	"mime/multipart"
-	"os"
 	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Declare global channels and WaitGroup for worker synchronization
 var (
-	TaskChan    chan *Task
-	ErrChan     chan error
-	WorkerWg    sync.WaitGroup
-	WorkerCount = 25 // Set the number of workers (can be adjusted)
+	TaskChan chan *Task
+	WorkerWg sync.WaitGroup
+
+	tracer = otel.Tracer("MAIN_SERVER/workerpool")
+
+	// quits holds one channel per running worker, closing it stops the worker
+	// once its current upload is done
+	quits     []chan bool
+	quitsLock sync.Mutex
 )
 
 // Task structure that holds the image and userName
 type Task struct {
+	Ctx      context.Context // Carries the logger and trace of the request that queued the task
 	File     *multipart.FileHeader
 	UserName string
+	Metadata dto.ImageMetadata
 }
 
-// InitializeWorkerPool initializes the worker pool with a fixed number of workers
+// InitializeWorkerPool initializes the worker pool with the configured number
+// of workers
 func InitializeWorkerPool() {
+	workers := config.Get().Workers
+
 	// Create channels with buffer size
-	TaskChan = make(chan *Task, 100) // The buffer size can be adjusted
-	ErrChan = make(chan error, 100)
+	TaskChan = make(chan *Task, workers.UploadQueue)
 
 	// Start the worker goroutines
-	for i := 0; i < WorkerCount; i++ {
-		go Worker(i)
+	Resize(workers.Uploads)
+
+	metrics.RegisterGaugeFunc("upload_queue_depth", "Files waiting for an upload worker.", func() float64 {
+		return float64(len(TaskChan))
+	})
+	metrics.RegisterGaugeFunc("upload_workers", "Running upload workers.", func() float64 {
+		quitsLock.Lock()
+		defer quitsLock.Unlock()
+		return float64(len(quits))
+	})
+}
+
+// Resize starts or stops workers until count are running, stopped workers
+// finish their current upload first
+func Resize(count int) {
+	quitsLock.Lock()
+	defer quitsLock.Unlock()
+
+	for len(quits) < count {
+		quit := make(chan bool)
+		quits = append(quits, quit)
+		go Worker(len(quits)-1, quit)
+	}
+	for len(quits) > count {
+		close(quits[len(quits)-1])
+		quits = quits[:len(quits)-1]
 	}
 }
 
-// Worker processes tasks (images) from the task channel
-func Worker(workerID int) {
-	for task := range TaskChan {
-		// Process the task (upload image)
+// Worker processes tasks (images) from the task channel until quit is closed
+func Worker(workerID int, quit <-chan bool) {
+	for {
+		var task *Task
+		select {
+		case task = <-TaskChan:
+		case <-quit:
+			return
+		}
+
+		// Process the task (upload image), the span joins the trace of the
+		// request even though it ended long ago
+		ctx, span := tracer.Start(task.Ctx, "upload image", trace.WithAttributes(
+			attribute.Int("worker.id", workerID),
+			attribute.String("file.name", task.File.Filename),
+			attribute.Int64("file.size", task.File.Size),
+		))
+		ctx = logging.With(ctx, "worker", workerID, "file", task.File.Filename)
+		logging.FromContext(ctx).Info("uploading image", "size_bytes", task.File.Size)
+
 		WorkerWg.Add(1)
-		fmt.Printf("Worker %d is uploading image: %v for user: %s\n", workerID, task.File.Filename, task.UserName)
-		// Simulate the image upload (call your actual upload function here)
-		queries.UploadImageToDrive(task.File, os.Getenv("FOLDER_ID"), &WorkerWg, ErrChan, task.UserName)
+		metrics.UploadWorkersBusy.Inc()
+		start := time.Now()
+
+		storage := config.Get().Storage
+		outcome := "success"
+		if err := queries.UploadImageToDrive(ctx, task.File, storage.FolderID, task.UserName, task.Metadata); err != nil {
+			outcome = "error"
+			logging.FromContext(ctx).Error("uploading image failed", "err", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "upload failed")
+		}
+		span.End()
+
+		metrics.UploadBytes.WithLabelValues(storage.Backend, outcome).Add(float64(task.File.Size))
+		metrics.UploadDuration.WithLabelValues(storage.Backend, outcome).Observe(time.Since(start).Seconds())
+		metrics.UploadWorkersBusy.Dec()
+		WorkerWg.Done()
 	}
 }
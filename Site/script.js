@@ -156,9 +156,9 @@ function previewFile(file) {
   preview.innerHTML = `
         <div class="preview-loading">Loading preview...</div>
         <div class="file-info">
-            <span class="file-name">${file.name}</span>
+            <span class="file-name">${escapeHTML(file.name)}</span>
         </div>
-        <div class="remove-file"  data-filename="${file.name}"><img  src="https://www.svgrepo.com/show/521590/cross.svg"></div>
+        <div class="remove-file"  data-filename="${escapeHTML(file.name)}"><img  src="https://www.svgrepo.com/show/521590/cross.svg"></div>
     `;
 
   selectedFilesContainer.appendChild(preview);
@@ -182,23 +182,22 @@ function previewFile(file) {
                     <div class="special-format-preview">
                         <div class="format-icon">📸</div>
                         <div class="format-info">
-                            <span class="format-name">${file.name
-                              .split(".")
-                              .pop()
-                              .toUpperCase()} Image</span>
+                            <span class="format-name">${escapeHTML(
+                              file.name.split(".").pop().toUpperCase()
+                            )} Image</span>
                         </div>
                     </div>
-                    <div class="remove-file"  data-filename="${
+                    <div class="remove-file"  data-filename="${escapeHTML(
                       file.name
-                    }"><img  src="https://www.svgrepo.com/show/521590/cross.svg"></div>
+                    )}"><img  src="https://www.svgrepo.com/show/521590/cross.svg"></div>
                 `;
       } else {
         preview.innerHTML = `
-                    <img src="${e.target.result}" alt="${file.name}">
+                    <img src="${e.target.result}" alt="${escapeHTML(file.name)}">
                     <div class="file-info">
-                        <span class="file-name">${file.name}</span>
+                        <span class="file-name">${escapeHTML(file.name)}</span>
                     </div>
-                    <div class="remove-file"  data-filename="${file.name}"><img  src="https://www.svgrepo.com/show/521590/cross.svg"></div>
+                    <div class="remove-file"  data-filename="${escapeHTML(file.name)}"><img  src="https://www.svgrepo.com/show/521590/cross.svg"></div>
                 `;
       }
     };
@@ -207,9 +206,9 @@ function previewFile(file) {
       preview.innerHTML = `
                 <div class="preview-error">Error loading preview</div>
                 <div class="file-info">
-                    <span class="file-name">${file.name}</span>
+                    <span class="file-name">${escapeHTML(file.name)}</span>
                 </div>
-                <div class="remove-file"  data-filename="${file.name}"><img  src="https://www.svgrepo.com/show/521590/cross.svg"></div>
+                <div class="remove-file"  data-filename="${escapeHTML(file.name)}"><img  src="https://www.svgrepo.com/show/521590/cross.svg"></div>
             `;
     };
 
@@ -254,7 +253,6 @@ async function uploadFiles(files) {
         // Normal file append
         formData.append("images", file);
       }
-      formData.append("username", localStorage.getItem("userName"));
     }
   });
 
@@ -283,7 +281,8 @@ async function uploadFiles(files) {
       document.getElementById("selectedFiles").innerHTML = "";
       document.getElementById("images").value = "";
     } else {
-      showToast("Upload failed.");
+      const body = await response.json().catch(() => null);
+      showToast(body?.error?.message || "Upload failed.");
     }
   } catch (error) {
     console.error("Upload Error:", error);
@@ -363,6 +362,63 @@ function handleViewChange(value) {
     }
   }
 }
+
+// Escape text for use in HTML templates
+function escapeHTML(text) {
+  return String(text ?? "")
+    .replaceAll("&", "&amp;")
+    .replaceAll("<", "&lt;")
+    .replaceAll(">", "&gt;")
+    .replaceAll('"', "&quot;")
+    .replaceAll("'", "&#39;");
+}
+
+// Create an element with a class and optional text, user supplied values
+// only ever go through textContent and attributes
+function createElement(tag, className, text) {
+  const element = document.createElement(tag);
+  if (className) {
+    element.className = className;
+  }
+  if (text !== undefined) {
+    element.textContent = text;
+  }
+  return element;
+}
+
+// Build the list entry of an image returned by the API
+function createImageItem(file) {
+  const div = createElement("div", "image-item");
+
+  const img = createElement("img");
+  img.src = file.thumbnail;
+  img.alt = file.alt_text || file.title || file.name;
+  img.loading = "lazy";
+  img.dataset.fileId = file.id;
+
+  const likeContainer = createElement("div", "like-container");
+
+  const download = createElement("button", "download-link", "Download");
+  download.dataset.url = file.download_url;
+  download.dataset.filename = file.name;
+
+  const likeGroup = createElement("div", "like-group");
+
+  const share = createElement("button", "share-link", "🔗");
+  share.dataset.fileId = file.id;
+
+  const like = createElement("button", "like-button", "❤️");
+  like.addEventListener("click", () => toggleLike(file.id));
+
+  const likeCount = createElement("span", "like-count", file.liked_count);
+  likeCount.id = `like-count-${file.id}`;
+
+  likeGroup.append(share, like, likeCount);
+  likeContainer.append(download, likeGroup);
+  div.append(img, createElement("div", "name", file.title || file.name), likeContainer);
+  return div;
+}
+
 // Function to load images with infinite scroll
 async function loadImages() {
   if (isLoading) return;
@@ -394,20 +450,7 @@ async function loadImages() {
     }
 
     data.files.forEach((file) => {
-      const div = document.createElement("div");
-      div.className = "image-item";
-      div.innerHTML = `
-                  <img src="${file.thumbnail}" alt="${file.name}" loading="lazy" data-file-id="${file.id}">
-                  <div class="name">${file.name}</div>
-                  <div class="like-container">
-                  <button class="download-link" data-url="${file.download_url}" data-filename="${file.name}">Download</button>
-                  <div class="like-group">
-                     <button class="share-link" data-file-id="${file.id}">🔗</button>
-                   <button class="like-button" onclick="toggleLike('${file.id}')">❤️</button>
-                  <span class="like-count" id="like-count-${file.id}">${file.liked_count}</span>
-                  </div>
-                  </div>
-              `;
+      const div = createImageItem(file);
       // Append the new images to the end of the existing list
       imageList.appendChild(div);
     });
@@ -546,7 +589,7 @@ function openModal(fileId) {
   const modal = document.getElementById("imagePreviewModal");
   const modalPreview = document.getElementById("modalPreview");
   // Use Google Drive's embed URL format
-  modalPreview.src = `https://drive.google.com/file/d/${fileId}/preview`;
+  modalPreview.src = `https://drive.google.com/file/d/${encodeURIComponent(fileId)}/preview`;
   modal.style.display = "block";
 
   // Add keyboard support for closing modal
@@ -0,0 +1,298 @@
+// Package config loads the typed configuration shared by the services.
+//
+// A service describes its settings as a struct. Fields are read from a JSON or
+// YAML file by their json/yaml tags, start from the value in their `default`
+// tag and can be overridden by the environment variable named in their `env`
+// tag. The `env` tag of a nested struct is a prefix for the variables of its
+// fields. After loading, a config implementing Validator is checked and every
+// bad field is reported at once.
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HTTP holds the listener settings every service has
+type HTTP struct {
+	ListenAddr  string   `json:"listen_addr" yaml:"listen_addr" env:"LISTEN_ADDR"`
+	CORSOrigins []string `json:"cors_origins" yaml:"cors_origins" env:"CORS_ORIGINS" default:"*"`
+	CORSMethods []string `json:"cors_methods" yaml:"cors_methods" env:"CORS_METHODS" default:"GET,POST,PUT,PATCH,DELETE"`
+}
+
+// corsMethods are the methods cors_methods may list
+var corsMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// Validate reports the bad HTTP settings under the given field prefix
+func (h HTTP) Validate(prefix string, p *Problems) {
+	if _, _, err := net.SplitHostPort(h.ListenAddr); err != nil {
+		p.Add(prefix+".listen_addr", "must be host:port, got %q", h.ListenAddr)
+	}
+	if len(h.CORSOrigins) == 0 {
+		p.Add(prefix+".cors_origins", "must list at least one origin, use * to allow all")
+	}
+	if len(h.CORSMethods) == 0 {
+		p.Add(prefix+".cors_methods", "must list at least one method")
+	}
+	for _, method := range h.CORSMethods {
+		if !slices.Contains(corsMethods, method) {
+			p.Add(prefix+".cors_methods", "must be one of %s, got %q", strings.Join(corsMethods, ", "), method)
+		}
+	}
+}
+
+// AllowMethods returns cors_methods as an Access-Control-Allow-Methods value
+func (h HTTP) AllowMethods() string {
+	return strings.Join(h.CORSMethods, ", ")
+}
+
+// AllowsOrigin reports whether a CORS request from origin is allowed
+func (h HTTP) AllowsOrigin(origin string) bool {
+	for _, allowed := range h.CORSOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Log holds the logger settings every service has
+type Log struct {
+	Level string `json:"level" yaml:"level" env:"LOG_LEVEL" default:"info"`
+}
+
+// Validate reports a bad log level under the given field prefix
+func (l Log) Validate(prefix string, p *Problems) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(l.Level)); err != nil {
+		p.Add(prefix+".level", "must be debug, info, warn or error, got %q", l.Level)
+	}
+}
+
+// SlogLevel returns the level, info when it is invalid
+func (l Log) SlogLevel() slog.Level {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(l.Level)); err != nil {
+		return slog.LevelInfo
+	}
+	return lvl
+}
+
+// Trace exporters
+const (
+	TraceExporterNone   = "none"
+	TraceExporterOTLP   = "otlp"
+	TraceExporterStdout = "stdout"
+)
+
+// Tracing holds the OpenTelemetry settings every service has, the exporter
+// is "none", "otlp" (OTLP over HTTP to Endpoint) or "stdout"
+type Tracing struct {
+	Exporter    string  `json:"exporter" yaml:"exporter" env:"OTEL_TRACES_EXPORTER" default:"none"`
+	Endpoint    string  `json:"endpoint" yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" default:"http://localhost:4318"`
+	SampleRatio float64 `json:"sample_ratio" yaml:"sample_ratio" env:"OTEL_TRACES_SAMPLER_ARG" default:"1"`
+}
+
+// Validate reports the bad tracing settings under the given field prefix
+func (t Tracing) Validate(prefix string, p *Problems) {
+	switch t.Exporter {
+	case TraceExporterNone, TraceExporterStdout:
+	case TraceExporterOTLP:
+		if u, err := url.Parse(t.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			p.Add(prefix+".endpoint", "must be an http(s) URL, got %q", t.Endpoint)
+		}
+	default:
+		p.Add(prefix+".exporter", "must be none, otlp or stdout, got %q", t.Exporter)
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		p.Add(prefix+".sample_ratio", "must be between 0 and 1, got %g", t.SampleRatio)
+	}
+}
+
+// Metrics holds the listener of the Prometheus endpoint, kept apart from the
+// public one. An empty address turns the endpoint off.
+type Metrics struct {
+	ListenAddr string `json:"listen_addr" yaml:"listen_addr" env:"METRICS_LISTEN_ADDR"`
+}
+
+// Enabled reports whether metrics are served
+func (m Metrics) Enabled() bool {
+	return m.ListenAddr != ""
+}
+
+// Validate reports the bad metrics settings under the given field prefix
+func (m Metrics) Validate(prefix string, p *Problems) {
+	if _, _, err := net.SplitHostPort(m.ListenAddr); m.Enabled() && err != nil {
+		p.Add(prefix+".listen_addr", "must be host:port, got %q", m.ListenAddr)
+	}
+}
+
+// Validator is implemented by configs that check their own values
+type Validator interface {
+	Validate(p *Problems)
+}
+
+// Load fills cfg, a pointer to a struct, from defaults, the file at path and
+// the environment, in that order of precedence. A missing file is not an
+// error, the defaults and environment are used alone.
+func Load(path string, cfg any) error {
+	target := reflect.ValueOf(cfg)
+	if target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, got %T", cfg)
+	}
+
+	var problems Problems
+	walk(target.Elem(), "", "", func(field reflect.Value, name, _ string, tag reflect.StructTag) {
+		if value, ok := tag.Lookup("default"); ok {
+			if err := setValue(field, value); err != nil {
+				problems.Add(name, "bad default %q: %v", value, err)
+			}
+		}
+	})
+
+	if err := decodeFile(path, cfg); err != nil {
+		return err
+	}
+
+	walk(target.Elem(), "", "", func(field reflect.Value, name, env string, _ reflect.StructTag) {
+		if env == "" {
+			return
+		}
+		if value, ok := os.LookupEnv(env); ok {
+			if err := setValue(field, value); err != nil {
+				problems.Add(name, "invalid %s=%q: %v", env, value, err)
+			}
+		}
+	})
+
+	if validator, ok := cfg.(Validator); ok {
+		validator.Validate(&problems)
+	}
+	return problems.Err()
+}
+
+// decodeFile decodes a .yaml/.yml file as YAML and anything else as JSON,
+// rejecting unknown keys so typos do not go unnoticed
+func decodeFile(path string, cfg any) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read config %s: %v", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to parse config %s: %v", path, err)
+	}
+	return nil
+}
+
+// walk calls fn for every leaf field with its dotted name and env variable
+func walk(v reflect.Value, prefix, envPrefix string, fn func(field reflect.Value, name, env string, tag reflect.StructTag)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			name = strings.ToLower(sf.Name)
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		env := sf.Tag.Get("env")
+		if env != "" {
+			env = envPrefix + env
+		}
+
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct && !field.Addr().Type().Implements(textUnmarshaler) {
+			walk(field, name, env, fn)
+			continue
+		}
+		fn(field, name, env, sf.Tag)
+	}
+}
+
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setValue parses a string into a leaf field, lists are comma-separated
+func setValue(field reflect.Value, value string) error {
+	if field.Addr().Type().Implements(textUnmarshaler) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", field.Type())
+		}
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
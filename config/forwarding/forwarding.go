@@ -0,0 +1,192 @@
+// Package forwarding handles the headers proxies use to pass on the client
+// of a request: X-Forwarded-For/-Proto/-Host, the RFC 7239 Forwarded header
+// and X-Request-ID. Forwarding headers are only believed when they come from
+// a trusted proxy.
+package forwarding
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+)
+
+// Proxies are the networks of trusted proxies
+type Proxies []*net.IPNet
+
+// ParseProxies parses IPs and CIDRs, loopback is always trusted
+func ParseProxies(entries []string) (Proxies, error) {
+	proxies := Proxies{}
+	for _, entry := range append([]string{"127.0.0.0/8", "::1/128"}, entries...) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if strings.Contains(entry, ":") {
+				entry += "/128"
+			} else {
+				entry += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP or CIDR", strings.TrimSuffix(strings.TrimSuffix(entry, "/32"), "/128"))
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// Contains reports whether ip belongs to a trusted proxy
+func (p Proxies) Contains(ip net.IP) bool {
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client. X-Forwarded-For, or the for=
+// values of Forwarded when it is absent, is only honored when remote is a
+// trusted proxy, and is walked from the right so clients cannot spoof
+// entries added by our own proxies.
+func (p Proxies) ClientIP(remote net.IP, xForwardedFor, forwarded string) net.IP {
+	if remote == nil || !p.Contains(remote) {
+		return remote
+	}
+
+	hops := strings.Split(xForwardedFor, ",")
+	if strings.TrimSpace(xForwardedFor) == "" {
+		hops = forwardedFor(forwarded)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseNode(hops[i])
+		if ip == nil {
+			break
+		}
+		if !p.Contains(ip) {
+			return ip
+		}
+	}
+	return remote
+}
+
+// forwardedFor returns the for= value of every Forwarded element
+func forwardedFor(forwarded string) []string {
+	var hops []string
+	for _, element := range strings.Split(forwarded, ",") {
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				hops = append(hops, value)
+			}
+		}
+	}
+	return hops
+}
+
+// parseNode parses an address of X-Forwarded-For or a Forwarded for= value,
+// which quotes IPv6 addresses in brackets and may carry a port
+func parseNode(node string) net.IP {
+	node = strings.Trim(strings.TrimSpace(node), `"`)
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+	return net.ParseIP(strings.Trim(node, "[]"))
+}
+
+// Header is the request header Apply edits, *fasthttp.RequestHeader or a
+// net/http header through HTTPHeader
+type Header interface {
+	Peek(key string) []byte
+	Set(key, value string)
+}
+
+// HTTPHeader adapts a net/http header to Apply
+type HTTPHeader http.Header
+
+func (h HTTPHeader) Peek(key string) []byte {
+	return []byte(http.Header(h).Get(key))
+}
+
+func (h HTTPHeader) Set(key, value string) {
+	http.Header(h).Set(key, value)
+}
+
+// Apply sets the forwarding headers of a request to host about to be
+// proxied. The headers of a trusted remote are extended with this hop,
+// anyone else's are replaced so clients cannot pose as someone else.
+func Apply(header Header, host string, remote net.IP, isTLS bool, proxies Proxies) {
+	proto := "http"
+	if isTLS {
+		proto = "https"
+	}
+
+	node := remote.String()
+	if remote.To4() == nil {
+		node = `"[` + node + `]"`
+	}
+	element := "for=" + node + ";proto=" + proto
+	if host != "" {
+		element += ";host=" + quote(host)
+	}
+
+	if proxies.Contains(remote) {
+		appendHeader(header, fasthttp.HeaderXForwardedFor, remote.String())
+		appendHeader(header, "Forwarded", element)
+		setDefault(header, fasthttp.HeaderXForwardedProto, proto)
+		setDefault(header, fasthttp.HeaderXForwardedHost, host)
+		return
+	}
+
+	header.Set(fasthttp.HeaderXForwardedFor, remote.String())
+	header.Set("Forwarded", element)
+	header.Set(fasthttp.HeaderXForwardedProto, proto)
+	header.Set(fasthttp.HeaderXForwardedHost, host)
+}
+
+func appendHeader(header Header, key, value string) {
+	if prior := string(header.Peek(key)); prior != "" {
+		value = prior + ", " + value
+	}
+	header.Set(key, value)
+}
+
+func setDefault(header Header, key, value string) {
+	if len(header.Peek(key)) == 0 && value != "" {
+		header.Set(key, value)
+	}
+}
+
+// quote quotes Forwarded values that are not plain tokens, such as hosts
+// with a port
+func quote(value string) string {
+	for _, r := range value {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", r)) {
+			return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+		}
+	}
+	return value
+}
+
+// maxRequestIDLength bounds the request IDs taken from clients
+const maxRequestIDLength = 128
+
+// RequestID returns the incoming X-Request-ID when it is safe to log and
+// pass on, a new one otherwise
+func RequestID(incoming string) string {
+	if incoming == "" || len(incoming) > maxRequestIDLength {
+		return uuid.NewString()
+	}
+	for _, r := range incoming {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:/+=", r)) {
+			return uuid.NewString()
+		}
+	}
+	return incoming
+}
@@ -0,0 +1,188 @@
+package forwarding
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func mustProxies(t *testing.T, entries ...string) Proxies {
+	t.Helper()
+	proxies, err := ParseProxies(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proxies
+}
+
+func TestParseProxies(t *testing.T) {
+	proxies := mustProxies(t, "10.0.0.1", " 10.1.0.0/16 ", "2001:db8::1", "2001:db8:1::/48", "", " ")
+
+	// Loopback is always trusted
+	for _, ip := range []string{"127.0.0.1", "127.9.9.9", "::1", "10.0.0.1", "10.1.200.3", "2001:db8::1", "2001:db8:1::9"} {
+		if !proxies.Contains(net.ParseIP(ip)) {
+			t.Errorf("%s is not trusted", ip)
+		}
+	}
+	for _, ip := range []string{"10.0.0.2", "10.2.0.1", "2001:db8::2", "::2"} {
+		if proxies.Contains(net.ParseIP(ip)) {
+			t.Errorf("%s is trusted", ip)
+		}
+	}
+}
+
+func TestParseProxiesErrors(t *testing.T) {
+	for _, entry := range []string{"10.0.0.300", "10.0.0.0/33", "proxy.internal"} {
+		_, err := ParseProxies([]string{entry})
+		if want := fmt.Sprintf("%q is not an IP or CIDR", entry); err == nil || err.Error() != want {
+			t.Errorf("ParseProxies(%q) error = %v, want %s", entry, err, want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name          string
+		remote        string
+		xForwardedFor string
+		forwarded     string
+		want          string
+	}{
+		{name: "direct client", remote: "203.0.113.9", want: "203.0.113.9"},
+		{name: "untrusted remote is not believed", remote: "203.0.113.9", xForwardedFor: "1.1.1.1", want: "203.0.113.9"},
+		{name: "trusted proxy", remote: "10.0.0.1", xForwardedFor: "198.51.100.7", want: "198.51.100.7"},
+		{name: "spoofed leftmost entry", remote: "10.0.0.1", xForwardedFor: "1.1.1.1, 198.51.100.7", want: "198.51.100.7"},
+		{name: "chain of trusted proxies", remote: "127.0.0.1", xForwardedFor: "198.51.100.7, 10.0.0.2, 10.0.0.1", want: "198.51.100.7"},
+		{name: "only proxies", remote: "10.0.0.1", xForwardedFor: "10.0.0.2", want: "10.0.0.1"},
+		{name: "garbage stops the walk", remote: "10.0.0.1", xForwardedFor: "198.51.100.7, junk", want: "10.0.0.1"},
+		{name: "forwarded", remote: "10.0.0.1", forwarded: "for=198.51.100.7;proto=https", want: "198.51.100.7"},
+		{name: "forwarded ipv6 with port", remote: "10.0.0.1", forwarded: `for="[2001:db8::7]:4711"`, want: "2001:db8::7"},
+		{name: "forwarded chain", remote: "10.0.0.1", forwarded: "for=1.1.1.1, for=198.51.100.7;host=x, For=10.0.0.2", want: "198.51.100.7"},
+		{name: "x-forwarded-for wins", remote: "10.0.0.1", xForwardedFor: "198.51.100.7", forwarded: "for=1.1.1.1", want: "198.51.100.7"},
+		{name: "ipv6 remote", remote: "::1", xForwardedFor: "2001:db8::7", want: "2001:db8::7"},
+	}
+
+	proxies := mustProxies(t, "10.0.0.0/24")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := proxies.ClientIP(net.ParseIP(test.remote), test.xForwardedFor, test.forwarded)
+			if got.String() != test.want {
+				t.Errorf("ClientIP() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		remote   string
+		host     string
+		isTLS    bool
+		incoming map[string]string
+		want     map[string]string
+	}{
+		{
+			name:     "untrusted headers are replaced",
+			remote:   "203.0.113.9",
+			host:     "example.com",
+			isTLS:    true,
+			incoming: map[string]string{"X-Forwarded-For": "1.1.1.1", "X-Forwarded-Proto": "http", "X-Forwarded-Host": "evil", "Forwarded": "for=1.1.1.1"},
+			want: map[string]string{
+				"X-Forwarded-For":   "203.0.113.9",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "example.com",
+				"Forwarded":         "for=203.0.113.9;proto=https;host=example.com",
+			},
+		},
+		{
+			name:     "trusted headers are extended",
+			remote:   "10.0.0.1",
+			host:     "example.com",
+			incoming: map[string]string{"X-Forwarded-For": "198.51.100.7", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "cdn.example.com", "Forwarded": "for=198.51.100.7"},
+			want: map[string]string{
+				"X-Forwarded-For":   "198.51.100.7, 10.0.0.1",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "cdn.example.com",
+				"Forwarded":         "for=198.51.100.7, for=10.0.0.1;proto=http;host=example.com",
+			},
+		},
+		{
+			name:   "trusted without headers",
+			remote: "10.0.0.1",
+			host:   "example.com:8443",
+			want: map[string]string{
+				"X-Forwarded-For":   "10.0.0.1",
+				"X-Forwarded-Proto": "http",
+				"X-Forwarded-Host":  "example.com:8443",
+				"Forwarded":         `for=10.0.0.1;proto=http;host="example.com:8443"`,
+			},
+		},
+		{
+			name:   "ipv6 client",
+			remote: "2001:db8::7",
+			want: map[string]string{
+				"X-Forwarded-For": "2001:db8::7",
+				"Forwarded":       `for="[2001:db8::7]";proto=http`,
+			},
+		},
+	}
+
+	proxies := mustProxies(t, "10.0.0.0/24")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Both header types must come out the same
+			var fastHeader fasthttp.RequestHeader
+			httpHeader := http.Header{}
+			for key, value := range test.incoming {
+				fastHeader.Set(key, value)
+				httpHeader.Set(key, value)
+			}
+
+			Apply(&fastHeader, test.host, net.ParseIP(test.remote), test.isTLS, proxies)
+			Apply(HTTPHeader(httpHeader), test.host, net.ParseIP(test.remote), test.isTLS, proxies)
+
+			for key, want := range test.want {
+				if got := string(fastHeader.Peek(key)); got != want {
+					t.Errorf("fasthttp %s = %q, want %q", key, got, want)
+				}
+				if got := httpHeader.Get(key); got != want {
+					t.Errorf("net/http %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		kept     bool
+	}{
+		{name: "uuid", incoming: "0f8fad5b-d9cb-469f-a165-70867728950e", kept: true},
+		{name: "trace style", incoming: "abc_123.x:y/z+w=", kept: true},
+		{name: "empty", incoming: ""},
+		{name: "too long", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "longest kept", incoming: strings.Repeat("a", maxRequestIDLength), kept: true},
+		{name: "log injection", incoming: "id\nlevel=ERROR"},
+		{name: "space", incoming: "a b"},
+		{name: "non ascii", incoming: "idé"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := RequestID(test.incoming)
+			if kept := got == test.incoming; kept != test.kept {
+				t.Errorf("RequestID(%q) = %q, kept = %v, want %v", test.incoming, got, kept, test.kept)
+			}
+			if got == "" {
+				t.Error("RequestID() is empty")
+			}
+		})
+	}
+}
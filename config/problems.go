@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError describes one bad setting
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every bad setting found while loading
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	lines := make([]string, len(e))
+	for i, fieldErr := range e {
+		lines[i] = "  " + fieldErr.Error()
+	}
+	return fmt.Sprintf("invalid configuration, %d problems:\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+// Problems collects field errors during validation
+type Problems struct {
+	errs ValidationError
+}
+
+// Add records a problem with field
+func (p *Problems) Add(field, format string, args ...any) {
+	p.errs = append(p.errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Require records a problem when value is empty
+func (p *Problems) Require(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		p.Add(field, "is required")
+	}
+}
+
+// Positive records a problem when value is not above zero
+func (p *Problems) Positive(field string, value int64) {
+	if value <= 0 {
+		p.Add(field, "must be positive, got %d", value)
+	}
+}
+
+// Err returns the collected problems as a ValidationError, or nil
+func (p *Problems) Err() error {
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return p.errs
+}
@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+)
+
+// HeaderCarrier adapts fasthttp headers to the propagation.TextMapCarrier
+// interface
+type HeaderCarrier struct {
+	Header *fasthttp.RequestHeader
+}
+
+func (h HeaderCarrier) Get(key string) string {
+	return string(h.Header.Peek(key))
+}
+
+func (h HeaderCarrier) Set(key, value string) {
+	h.Header.Set(key, value)
+}
+
+func (h HeaderCarrier) Keys() []string {
+	keys := []string{}
+	h.Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// Extract returns ctx carrying the trace context of the incoming headers
+func Extract(ctx context.Context, header *fasthttp.RequestHeader) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier{header})
+}
+
+// Inject writes the trace context of ctx into outgoing headers
+func Inject(ctx context.Context, header *fasthttp.RequestHeader) {
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier{header})
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Watcher calls a reload function on SIGHUP and whenever the config file
+// changes, counting the outcomes
+type Watcher struct {
+	path     string
+	reload   func() error
+	interval time.Duration
+	modTime  time.Time
+	signals  chan os.Signal
+	done     chan bool
+
+	succeeded atomic.Int64
+	failed    atomic.Int64
+}
+
+// Watch starts watching path, polling its modification time every interval.
+// reload should load and validate the new config and only swap it in when
+// it is valid, a failed reload keeps the previous config.
+func Watch(path string, interval time.Duration, reload func() error) *Watcher {
+	w := &Watcher{
+		path:     path,
+		reload:   reload,
+		interval: interval,
+		modTime:  modTime(path),
+		signals:  make(chan os.Signal, 1),
+		done:     make(chan bool),
+	}
+
+	signal.Notify(w.signals, syscall.SIGHUP)
+	go w.start()
+	return w
+}
+
+func (w *Watcher) start() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.signals:
+			w.modTime = modTime(w.path)
+			w.run("SIGHUP")
+		case <-ticker.C:
+			if changed := modTime(w.path); !changed.Equal(w.modTime) {
+				w.modTime = changed
+				w.run("file change")
+			}
+		case <-w.done:
+			signal.Stop(w.signals)
+			return
+		}
+	}
+}
+
+// run reloads once and logs the outcome
+func (w *Watcher) run(trigger string) {
+	if err := w.reload(); err != nil {
+		w.failed.Add(1)
+		slog.Error("config reload failed, keeping the previous config", "path", w.path, "trigger", trigger, "err", err)
+		return
+	}
+	w.succeeded.Add(1)
+	slog.Info("config reloaded", "path", w.path, "trigger", trigger)
+}
+
+// Reloads returns how many reloads succeeded and failed so far
+func (w *Watcher) Reloads() (succeeded, failed int64) {
+	return w.succeeded.Load(), w.failed.Load()
+}
+
+func (w *Watcher) Stop() {
+	w.done <- true
+}
+
+// modTime returns the file modification time, zero when it is missing
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -2,8 +2,9 @@ package main
 
 import (
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"time"
 )
 
@@ -12,6 +13,8 @@ var urls = []string{
 }
 
 func main() {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
 	// Start individual health check routines for each URL
 	for _, url := range urls {
 		go continuousHealthCheck(url)
@@ -19,7 +22,8 @@ func main() {
 
 	// Start the HTTP server
 	if err := http.ListenAndServe("0.0.0.0:8000", nil); err != nil {
-		log.Fatalf("HTTP server error: %v", err)
+		slog.Error("HTTP server error", "err", err)
+		os.Exit(1)
 	}
 }
 
@@ -28,25 +32,25 @@ func continuousHealthCheck(url string) {
 		Timeout: 120 * time.Second,
 	}
 
-	log.Printf("Starting continuous health check for %s", url)
+	slog.Info("starting continuous health check", "url", url)
 
 	for {
 		// Create a new goroutine for each individual request
 		go func(checkUrl string) {
 			resp, err := client.Get(checkUrl)
 			if err != nil {
-				log.Printf("Error checking %s: %v", checkUrl, err)
+				slog.Error("health check failed", "url", checkUrl, "err", err)
 				return
 			}
 			defer resp.Body.Close()
 
 			_, err = io.ReadAll(resp.Body)
 			if err != nil {
-				log.Printf("Error reading response from %s: %v", checkUrl, err)
+				slog.Error("reading health check response failed", "url", checkUrl, "err", err)
 				return
 			}
 
-			log.Printf("Status for %s: %d", checkUrl, resp.StatusCode)
+			slog.Info("health check", "url", checkUrl, "status", resp.StatusCode)
 		}(url)
 
 		// Wait for 30 seconds before next check